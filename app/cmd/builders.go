@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var buildersCmd = &cobra.Command{
+	Use:   "builders",
+	Short: "List buildx builders",
+	Long:  `Lists the nodes of buildx docker-container builders, found from their buildx_buildkit_* containers and state volumes, with their size and last activity.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunBuildersList()
+	},
+}
+
+var buildersCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Clean stale buildx builders",
+	Long: `Removes the nodes of buildx docker-container builders stopped for longer than --older-than,
+7 days when it is not set: their BuildKit container, then the state volume holding their build cache. Running builders
+are never touched.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunBuildersCleanup()
+	},
+}
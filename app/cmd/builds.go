@@ -20,6 +20,10 @@ var buildsCmd = &cobra.Command{
 		}
 		defer ctrl.Close()
 
+		if controllers.GetConfig().BuildsReport {
+			ctrl.RunBuildsReport()
+			return
+		}
 		ctrl.RunBuildsCleanup()
 	},
 }
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix is the prefix of the environment variables that set flags
+const envPrefix = "DOCKER_CLEANUP_"
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the policy configuration",
+	Long: `Inspects the policy configuration.
+
+Settings are resolved with the following precedence: command line flags, then
+DOCKER_CLEANUP_* environment variables, then the policy file given with --config,
+$XDG_CONFIG_HOME/docker-cleanup/config.yaml or /etc/docker-cleanup/config.yaml.`,
+	// The config subcommands load the policy themselves to report its errors
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration",
+	Long: `Shows the configuration resulting from the policy file, environment variables and flags,
+including the flags of the cleanup commands that override rule fields, such as --keep-last.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		return controllers.ShowConfig()
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [FILE]",
+	Short: "Validate a policy file",
+	Long:  `Validates a policy file, or the one that would be loaded when no file is given.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The policy file is found as the cleanup commands find it, --config then DOCKER_CLEANUP_CONFIG
+		if _, err := applyEnv(cmd.Flags()); err != nil {
+			return err
+		}
+		file := controllers.GetConfig().ConfigFile
+		if len(args) > 0 {
+			file = args[0]
+		}
+		return controllers.ValidateConfig(file)
+	},
+}
+
+// envName returns the environment variable that sets a flag
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// applyEnv sets the flags that were not given from their environment variables, and returns the ones it set
+func applyEnv(flags *pflag.FlagSet) (map[string]bool, error) {
+	fromEnv := make(map[string]bool)
+
+	var errs []error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Changed || f.Name == "help" {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if err := f.Value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", envName(f.Name), err))
+			return
+		}
+		fromEnv[f.Name] = true
+	})
+	return fromEnv, errors.Join(errs...)
+}
+
+// loadConfig applies environment variables and the policy file to the flags that were not given
+func loadConfig(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	fromEnv, err := applyEnv(flags)
+	if err != nil {
+		return err
+	}
+
+	conf := controllers.GetConfig()
+	conf.OlderThanSet = flags.Changed("older-than") || fromEnv["older-than"]
+	policy, file, err := controllers.LoadPolicy(conf.ConfigFile)
+	if err != nil {
+		return err
+	}
+	conf.ConfigFile = file
+	conf.Policy = policy
+
+	resolved, err := policy.Resolve(conf.Profile)
+	if err != nil {
+		return err
+	}
+	if conf.Profile == "" {
+		conf.Profile = policy.DefaultProfile
+	}
+	conf.Rules = resolved.Rules
+	conf.Budgets = resolved.Budgets
+
+	for name, values := range resolved.Values() {
+		f := flags.Lookup(name)
+		if f == nil || f.Changed || fromEnv[name] {
+			continue
+		}
+		for _, value := range values {
+			if err := f.Value.Set(value); err != nil {
+				return fmt.Errorf("%s: %s: %v", file, name, err)
+			}
+		}
+	}
+	return conf.Validate()
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var configsCmd = &cobra.Command{
+	Use:   "configs",
+	Short: "Clean unused swarm configs",
+	Long:  `Cleans swarm configs that no service uses. Only runs on swarm managers.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunConfigCleanup()
+	},
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var expiredCmd = &cobra.Command{
+	Use:   "expired",
+	Short: "Remove resources whose TTL has passed",
+	Long: `Removes the containers, networks, volumes and images labeled with docker-cleanup.ttl
+(a lifetime from their creation, such as 2h) or docker-cleanup.expires (an RFC3339 time)
+once it has passed. Running containers are stopped gracefully first, and volumes go
+through the same remote, database and backup rules as the volumes command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunExpiredCleanup()
+	},
+}
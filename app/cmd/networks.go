@@ -20,6 +20,13 @@ var networksCmd = &cobra.Command{
 		}
 		defer ctrl.Close()
 
-		ctrl.RunNetworkCleanup()
+		switch {
+		case controllers.GetConfig().FreeSubnets > 0:
+			ctrl.RunSubnetReclaim(controllers.GetConfig().FreeSubnets)
+		case controllers.GetConfig().IPAMReport:
+			ctrl.RunIPAMReport()
+		default:
+			ctrl.RunNetworkCleanup()
+		}
 	},
 }
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Clean disabled and unused plugins",
+	Long:  `Cleans disabled managed plugins, and volume and network driver plugins that no volume or network uses.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunPluginCleanup()
+	},
+}
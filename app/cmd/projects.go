@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var projectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "List Docker Compose projects",
+	Long:  `Lists Docker Compose projects with their state and last activity, grouping their containers, networks, volumes and images.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunProjectsList()
+	},
+}
+
+var projectsCleanCmd = &cobra.Command{
+	Use:   "clean [PROJECT...]",
+	Short: "Clean stale Docker Compose projects",
+	Long: `Removes stale Docker Compose projects: their containers, then their networks and, with
+--include-volumes, their volumes. Without arguments, every fully stopped project inactive for longer
+than --older-than (7 days when unset) is removed. Projects with a running, paused or restarting
+container are never touched.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunProjectsCleanup(args)
+	},
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreVolumeCmd = &cobra.Command{
+	Use:   "restore-volume ARCHIVE",
+	Short: "Restore a volume from a backup",
+	Long: `Recreates a volume removed with --backup-dir from its .tar.zst archive, with the driver, options
+and labels recorded in the JSON metadata file next to it. The volume must not exist.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunRestoreVolume(args[0])
+	},
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"docker-cleanup/app/controllers"
+	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -10,22 +11,106 @@ var rootCmd = &cobra.Command{
 	Use:   "docker-cleanup",
 	Short: "Docker Cleanup Tool - Clean unused Docker resources",
 	Long:  `A CLI tool to easily clean stopped containers, unused images, volumes, and networks in Docker.`,
+	// Configuration errors are reported without the usage text
+	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig(cmd)
+	},
 }
 
 func Execute() {
 	rootCmd.PersistentFlags().BoolVar(&controllers.GetConfig().DryRun, "dry-run", false, "Run in dry run mode (default: false)")
-	rootCmd.PersistentFlags().IntVar(&controllers.GetConfig().OlderThan, "older-than", 0, "Keep resources older than N days (default: 0)")
+	rootCmd.PersistentFlags().Var(&controllers.GetConfig().OlderThan, "older-than", "Only remove resources older than N days, or a duration such as 36h or 2w")
 	rootCmd.PersistentFlags().BoolVar(&controllers.GetConfig().ShowSize, "show-size", false, "Show size of resources (default: false)")
+	rootCmd.PersistentFlags().StringVar(&controllers.GetConfig().Where, "where", "", `Only remove resources matching an expression, e.g. 'kind == "image" && size > 1GB && age > 14d'`)
+	rootCmd.PersistentFlags().StringVar(&controllers.GetConfig().Profile, "profile", "", "Cleanup profile to apply, from the policy file or built-in: ci, dev-laptop, aggressive")
+	rootCmd.PersistentFlags().StringArrayVar(&controllers.GetConfig().ProtectWorkspaces, "protect-workspace", nil, "Keep the images, volumes and networks referenced by the Compose files and Dockerfiles under DIR (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&controllers.GetConfig().ByProject, "by-project", false, "Group listed resources by Docker Compose project (default: false)")
+	rootCmd.PersistentFlags().StringVar(&controllers.GetConfig().ConfigFile, "config", "", "Policy file to load (default: $XDG_CONFIG_HOME/docker-cleanup/config.yaml, then /etc/docker-cleanup/config.yaml)")
+
+	// config show takes the flags that override rule fields, to show the rules they result in
+	for _, cmd := range []*cobra.Command{imagesCmd, allCmd, configShowCmd} {
+		cmd.Flags().IntVar(&controllers.GetConfig().KeepLast, "keep-last", 0, "Keep the N most recent tags of each image repository (default: 0, disabled)")
+		cmd.Flags().StringVar(&controllers.GetConfig().KeepLastOrder, "keep-last-order", "", "Rank tags by creation time or semantic version: time, semver (default: time)")
+		cmd.Flags().StringArrayVar(&controllers.GetConfig().KeepLastRepositories, "keep-last-repo", nil, "Only apply --keep-last to repositories matching this pattern (repeatable)")
+	}
+
+	for _, cmd := range []*cobra.Command{containersCmd, allCmd, configShowCmd} {
+		cmd.Flags().IntSliceVar(&controllers.GetConfig().ExitCodes, "exit-code", nil, "Only remove containers that exited with this code (repeatable)")
+		cmd.Flags().IntSliceVar(&controllers.GetConfig().ExcludeExitCodes, "exclude-exit-code", nil, "Never remove containers that exited with this code (repeatable)")
+		cmd.Flags().Var(&controllers.GetConfig().KeepSucceeded, "keep-succeeded", "Keep containers that exited with code 0 for N days, or a duration such as 1h")
+		cmd.Flags().Var(&controllers.GetConfig().KeepFailed, "keep-failed", "Keep containers that exited with another code than 0 for N days, or a duration such as 7d")
+		cmd.Flags().StringToStringVar(&controllers.GetConfig().LifecycleRetention, "lifecycle-retention", nil, "Keep stopped containers of a lifecycle class for a duration after they stopped, e.g. never-started=1d,restart-policy=30d (classes: dead, never-started, restart-policy, exited)")
+	}
+
+	for _, cmd := range []*cobra.Command{buildsCmd, allCmd, configShowCmd} {
+		cmd.Flags().Var(&controllers.GetConfig().KeepStorage, "keep-storage", "Keep up to this much of the most recently used build cache, e.g. 10GB")
+		cmd.Flags().StringArrayVar(&controllers.GetConfig().CacheTypes, "cache-type", nil, "Only remove build cache records of this type: regular, source.local, source.git.checkout, exec.cachemount, frontend, internal (repeatable)")
+		cmd.Flags().BoolVar(&controllers.GetConfig().SharedOnly, "shared-only", false, "Only remove build cache records shared with other records (default: false)")
+		cmd.Flags().BoolVar(&controllers.GetConfig().ExcludeShared, "exclude-shared", false, "Never remove build cache records shared with other records (default: false)")
+		cmd.Flags().Var(&controllers.GetConfig().UnusedFor, "unused-for", "Only remove build cache records not used for N days, or a duration such as 36h or 2w")
+	}
+
+	for _, cmd := range []*cobra.Command{volumesCmd, allCmd, configShowCmd} {
+		cmd.Flags().Var(&controllers.GetConfig().UnwrittenFor, "unwritten-for", "Only remove volumes whose content was not written for N days, or a duration such as 36h or 2w")
+		cmd.Flags().BoolVar(&controllers.GetConfig().IncludeNamedVolumes, "include-named", false, "Also remove named volumes, only anonymous volumes are removed otherwise (default: false)")
+	}
+
+	// Every command removing volumes keeps remote and database volumes, and backs them up, the same way
+	for _, cmd := range []*cobra.Command{volumesCmd, allCmd, projectsCleanCmd, expiredCmd, configShowCmd} {
+		cmd.Flags().StringArrayVar(&controllers.GetConfig().VolumeDrivers, "volume-driver", nil, "Also remove the volumes of this driver, only local volumes are removed otherwise (repeatable)")
+		cmd.Flags().BoolVar(&controllers.GetConfig().IncludeRemoteVolumes, "include-remote-volumes", false, "Also remove volumes that may hold remote data: other drivers than local and NFS or CIFS mounts (default: false)")
+		cmd.Flags().BoolVar(&controllers.GetConfig().IncludeDatabases, "include-databases", false, "Also remove volumes holding the data files of a database (default: false)")
+	}
+	for _, cmd := range []*cobra.Command{volumesCmd, allCmd, projectsCleanCmd, expiredCmd} {
+		cmd.Flags().StringVar(&controllers.GetConfig().BackupDir, "backup-dir", "", "Archive each volume to DIR/<volume>-<timestamp>.tar.zst before removing it")
+	}
+
+	for _, cmd := range []*cobra.Command{networksCmd, allCmd, configShowCmd} {
+		cmd.Flags().BoolVar(&controllers.GetConfig().IgnoreStoppedAttachments, "ignore-stopped-attachments", false, "Also remove networks only stopped containers are attached to (default: false)")
+	}
+
+	networksCmd.Flags().BoolVar(&controllers.GetConfig().IPAMReport, "ipam", false, "Show the subnet of each network, the address pool it consumes and the subnets left instead of cleaning networks (default: false)")
+	networksCmd.Flags().IntVar(&controllers.GetConfig().FreeSubnets, "free-subnets", 0, "Only remove the oldest unused bridge networks until N subnets are free in the address pools")
+
+	for _, cmd := range []*cobra.Command{expiredCmd, allCmd, configShowCmd} {
+		cmd.Flags().Var(&controllers.GetConfig().StopTimeout, "stop-timeout", "Give expired running containers this long to stop before killing them, e.g. 30s (default: 10s)")
+	}
+
+	restoreVolumeCmd.Flags().StringVar(&controllers.GetConfig().RestoreName, "name", "", "Name of the restored volume (default: the name of the backed up volume)")
+
+	buildsCmd.Flags().BoolVar(&controllers.GetConfig().BuildsReport, "report", false, "Show the build cache grouped by record type and build step instead of cleaning it (default: false)")
+
+	for _, cmd := range []*cobra.Command{allCmd, configShowCmd} {
+		cmd.Flags().BoolVar(&controllers.GetConfig().IncludePlugins, "include-plugins", false, "Also remove disabled and unused plugins (default: false)")
+	}
+
+	projectsCleanCmd.Flags().BoolVar(&controllers.GetConfig().IncludeProjectVolumes, "include-volumes", false, "Also remove the volumes of the projects (default: false)")
+	projectsCmd.AddCommand(projectsCleanCmd)
+
+	buildersCmd.AddCommand(buildersCleanCmd)
+
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
 
 	rootCmd.AddCommand(containersCmd)
 	rootCmd.AddCommand(imagesCmd)
 	rootCmd.AddCommand(networksCmd)
 	rootCmd.AddCommand(volumesCmd)
+	rootCmd.AddCommand(restoreVolumeCmd)
 	rootCmd.AddCommand(danglingImagesCmd)
 	rootCmd.AddCommand(allCmd)
+	rootCmd.AddCommand(expiredCmd)
 	rootCmd.AddCommand(buildsCmd)
+	rootCmd.AddCommand(buildersCmd)
+	rootCmd.AddCommand(configsCmd)
+	rootCmd.AddCommand(secretsCmd)
+	rootCmd.AddCommand(pluginsCmd)
+	rootCmd.AddCommand(servicesCmd)
+	rootCmd.AddCommand(projectsCmd)
+	rootCmd.AddCommand(configCmd)
 
 	if err := rootCmd.Execute(); err != nil {
-		panic(err)
+		os.Exit(1)
 	}
 }
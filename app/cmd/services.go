@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"docker-cleanup/app/controllers"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var servicesCmd = &cobra.Command{
+	Use:   "services",
+	Short: "Clean swarm services scaled to zero",
+	Long: `Cleans replicated swarm services scaled to zero replicas and not updated for longer than --older-than,
+7 days when it is not set. The images, networks, configs and secrets they keep in use are listed with each service.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctrl, err := controllers.NewController()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer ctrl.Close()
+
+		ctrl.RunServiceCleanup()
+	},
+}
@@ -9,9 +9,10 @@ import (
 )
 
 var volumesCmd = &cobra.Command{
-	Use:   "volumes",
+	Use:   "volumes [VOLUME...]",
 	Short: "Clean unused volumes",
-	Long:  `Cleans unused volumes in Docker.`,
+	Long: `Cleans unused volumes in Docker. When volumes are named, only these are removed, whatever
+their age and whether they hold a database.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		ctrl, err := controllers.NewController()
 		if err != nil {
@@ -20,6 +21,6 @@ var volumesCmd = &cobra.Command{
 		}
 		defer ctrl.Close()
 
-		ctrl.RunVolumeCleanup()
+		ctrl.RunVolumeCleanup(args)
 	},
 }
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+)
+
+// RunBuildersList displays the buildx builder nodes with their size and last activity
+func (c *Controller) RunBuildersList() {
+	c.view.ShowTitle("Buildx builders:")
+
+	builders, err := c.model.GetBuilders()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving builders: %v", err))
+		return
+	}
+
+	c.view.ShowBuilders(builders)
+}
+
+// RunBuildersCleanup removes the buildx builder nodes stopped for longer than the age setting, with their state volume
+// Running builders are never touched
+func (c *Controller) RunBuildersCleanup() {
+	c.view.ShowTitle("Removing stale buildx builders...")
+
+	builders, err := c.model.GetBuilders()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving builders: %v", err))
+		return
+	}
+
+	var stopped []models.Builder
+	for _, builder := range builders {
+		if !builder.Running {
+			stopped = append(stopped, builder)
+		}
+	}
+	stale, _ := selectResources(c, KindBuilders, stopped, models.BuilderResource)
+
+	c.view.ShowStaleBuilders(stale, GetConfig().DryRun)
+
+	if GetConfig().DryRun {
+		return
+	}
+	for _, builder := range stale {
+		c.removeBuilder(builder)
+	}
+}
+
+// removeBuilder removes the container, then the state volume of a builder node
+func (c *Controller) removeBuilder(builder models.Builder) {
+	if builder.Container != nil {
+		if err := c.model.RemoveContainer(builder.Container.ID); err != nil {
+			c.view.ShowError(fmt.Errorf("error removing container of builder %s: %v", builder.Name, err))
+			return
+		}
+	}
+	if builder.Volume != "" {
+		if err := c.model.RemoveVolume(builder.Volume); err != nil {
+			c.view.ShowError(fmt.Errorf("error removing state volume of builder %s: %v", builder.Name, err))
+			return
+		}
+	}
+	c.view.ShowBuilderRemoved(builder)
+}
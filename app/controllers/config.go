@@ -1,9 +1,101 @@
 package controllers
 
+import (
+	"docker-cleanup/app/units"
+	"docker-cleanup/app/views"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 type config struct {
 	DryRun    bool
-	OlderThan int
-	ShowSize  bool
+	OlderThan Duration
+	// OlderThanSet is true when --older-than or DOCKER_CLEANUP_OLDER_THAN is given, replacing the default age of
+	// the kinds that have one, unlike an older_than setting of the policy file
+	OlderThanSet bool
+	ShowSize     bool
+	// Where is an expression every selected resource must satisfy
+	Where string
+
+	// ProtectWorkspaces lists directories whose Compose files and Dockerfiles protect the resources they reference
+	ProtectWorkspaces []string
+
+	// ByProject groups the listed resources by Docker Compose project
+	ByProject bool
+	// IncludeProjectVolumes also removes the volumes of stale Docker Compose projects
+	IncludeProjectVolumes bool
+
+	// IncludePlugins makes the all command also remove unused plugins
+	IncludePlugins bool
+
+	// KeepLast, KeepLastOrder and KeepLastRepositories override the keep-last retention of the images rule
+	KeepLast             int
+	KeepLastOrder        string
+	KeepLastRepositories []string
+
+	// ExitCodes, ExcludeExitCodes, KeepSucceeded and KeepFailed override the exit code selection of the containers rule
+	ExitCodes        []int
+	ExcludeExitCodes []int
+	KeepSucceeded    Duration
+	KeepFailed       Duration
+
+	// LifecycleRetention overrides the retention of the lifecycle classes of stopped containers
+	LifecycleRetention map[string]string
+
+	// IncludeNamedVolumes also removes named volumes, only anonymous ones being removed otherwise
+	IncludeNamedVolumes bool
+
+	// VolumeDrivers and IncludeRemoteVolumes also remove volumes that are not stored on the host
+	VolumeDrivers        []string
+	IncludeRemoteVolumes bool
+
+	// IncludeDatabases also removes volumes holding the data files of a database
+	IncludeDatabases bool
+
+	// BackupDir is where volumes are archived before they are removed, empty to remove them without a backup
+	BackupDir string
+	// RestoreName is the name of the volume recreated by restore-volume, the backed up one when empty
+	RestoreName string
+
+	// UnwrittenFor overrides the minimum time since the content of volumes was last written
+	UnwrittenFor Duration
+
+	// IgnoreStoppedAttachments also removes networks only stopped containers are attached to
+	IgnoreStoppedAttachments bool
+
+	// IPAMReport shows the address pool report instead of cleaning networks
+	IPAMReport bool
+	// FreeSubnets removes the oldest unused bridge networks until this many subnets are free in the address pools
+	FreeSubnets int
+
+	// StopTimeout overrides how long expired running containers are given to stop before they are killed
+	StopTimeout Duration
+
+	// BuildsReport shows the build cache report instead of cleaning builds
+	BuildsReport bool
+
+	// KeepStorage, CacheTypes, SharedOnly, ExcludeShared and UnusedFor override the build cache selection of the builds rule
+	KeepStorage   ByteSize
+	CacheTypes    []string
+	SharedOnly    bool
+	ExcludeShared bool
+	UnusedFor     Duration
+
+	// ConfigFile is the policy file that was loaded, empty when none was found
+	ConfigFile string
+	// Policy is the content of the policy file
+	Policy Policy
+	// Profile is the name of the selected profile, empty when none is used
+	Profile string
+	// Rules holds the per-kind rules of the policy file with the selected profile applied
+	Rules map[string]Rule
+	// Budgets holds the image storage budgets of the policy file with the selected profile applied
+	Budgets map[string]ByteSize
 }
 
 var conf = config{
@@ -15,3 +107,248 @@ var conf = config{
 func GetConfig() *config {
 	return &conf
 }
+
+// Duration is an age setting that accepts a number of days or a duration with a unit
+// It can be used both as a command line flag and as a policy file value
+type Duration time.Duration
+
+// String returns the duration in its shortest unit form
+func (d *Duration) String() string {
+	return units.FormatDuration(time.Duration(*d))
+}
+
+// Set parses the duration from a flag or environment value
+func (d *Duration) Set(value string) error {
+	parsed, err := units.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Type returns the flag type name
+func (d *Duration) Type() string {
+	return "duration"
+}
+
+// UnmarshalText parses the duration from a policy file value
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// MarshalText formats the duration for a policy file
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// ByteSize is a size setting that accepts a number of bytes with an optional unit
+type ByteSize int64
+
+// String returns the size in its shortest unit form
+func (s *ByteSize) String() string {
+	return units.FormatSize(int64(*s))
+}
+
+// Set parses the size from a flag or environment value
+func (s *ByteSize) Set(value string) error {
+	parsed, err := units.ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = ByteSize(parsed)
+	return nil
+}
+
+// Type returns the flag type name
+func (s *ByteSize) Type() string {
+	return "size"
+}
+
+// UnmarshalText parses the size from a policy file value
+func (s *ByteSize) UnmarshalText(text []byte) error {
+	return s.Set(string(text))
+}
+
+// MarshalText formats the size for a policy file
+func (s ByteSize) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Validate checks the settings given on the command line or in the environment
+func (c *config) Validate() error {
+	if c.Where != "" {
+		if _, err := compileWhere(c.Where); err != nil {
+			return fmt.Errorf("--where: %v", err)
+		}
+	}
+	if c.KeepLast < 0 {
+		return fmt.Errorf("--keep-last must not be negative")
+	}
+	if err := validateKeepLast(c.KeepLastOrder, c.KeepLastRepositories); err != nil {
+		return err
+	}
+	if err := validateLifecycleClasses(slices.Collect(maps.Keys(c.LifecycleRetention))); err != nil {
+		return fmt.Errorf("--lifecycle-retention: %v", err)
+	}
+	for class, value := range c.LifecycleRetention {
+		if _, err := units.ParseDuration(value); err != nil {
+			return fmt.Errorf("--lifecycle-retention %s: %v", class, err)
+		}
+	}
+	if c.FreeSubnets < 0 {
+		return fmt.Errorf("--free-subnets must not be negative")
+	}
+	if c.KeepStorage < 0 {
+		return fmt.Errorf("--keep-storage must not be negative")
+	}
+	if c.SharedOnly && c.ExcludeShared {
+		return fmt.Errorf("--shared-only and --exclude-shared cannot be used together")
+	}
+	return validateCacheTypes(c.CacheTypes)
+}
+
+// flagRules returns the rule fields set by flags or environment variables, by kind
+func (c *config) flagRules() map[string]Rule {
+	rules := make(map[string]Rule)
+	update := func(kind string, set func(*Rule)) {
+		rule := rules[kind]
+		set(&rule)
+		rules[kind] = rule
+	}
+
+	if c.KeepLast > 0 {
+		update(KindImages, func(r *Rule) { r.KeepLast = &c.KeepLast })
+	}
+	if c.KeepLastOrder != "" {
+		update(KindImages, func(r *Rule) { r.KeepLastOrder = c.KeepLastOrder })
+	}
+	if len(c.KeepLastRepositories) > 0 {
+		update(KindImages, func(r *Rule) { r.KeepLastRepositories = c.KeepLastRepositories })
+	}
+
+	if len(c.ExitCodes) > 0 {
+		update(KindContainers, func(r *Rule) { r.ExitCodes = c.ExitCodes })
+	}
+	if len(c.ExcludeExitCodes) > 0 {
+		update(KindContainers, func(r *Rule) { r.ExcludeExitCodes = c.ExcludeExitCodes })
+	}
+	if c.KeepSucceeded > 0 {
+		update(KindContainers, func(r *Rule) { r.KeepSucceeded = &c.KeepSucceeded })
+	}
+	if c.KeepFailed > 0 {
+		update(KindContainers, func(r *Rule) { r.KeepFailed = &c.KeepFailed })
+	}
+	if len(c.LifecycleRetention) > 0 {
+		retention := make(map[string]Duration)
+		for class, value := range c.LifecycleRetention {
+			// Flag values are validated when the configuration is loaded
+			parsed, _ := units.ParseDuration(value)
+			retention[class] = Duration(parsed)
+		}
+		update(KindContainers, func(r *Rule) { r.LifecycleRetention = retention })
+	}
+
+	if c.UnwrittenFor > 0 {
+		update(KindVolumes, func(r *Rule) { r.UnwrittenFor = &c.UnwrittenFor })
+	}
+	if c.IncludeNamedVolumes {
+		update(KindVolumes, func(r *Rule) { r.IncludeNamed = &c.IncludeNamedVolumes })
+	}
+	if len(c.VolumeDrivers) > 0 {
+		update(KindVolumes, func(r *Rule) { r.VolumeDrivers = c.VolumeDrivers })
+	}
+	if c.IncludeRemoteVolumes {
+		update(KindVolumes, func(r *Rule) { r.IncludeRemote = &c.IncludeRemoteVolumes })
+	}
+	if c.IncludeDatabases {
+		update(KindVolumes, func(r *Rule) { r.IncludeDatabases = &c.IncludeDatabases })
+	}
+
+	if c.IgnoreStoppedAttachments {
+		update(KindNetworks, func(r *Rule) { r.IgnoreStoppedAttachments = &c.IgnoreStoppedAttachments })
+	}
+	if c.StopTimeout > 0 {
+		update(KindExpired, func(r *Rule) { r.StopTimeout = &c.StopTimeout })
+	}
+	if c.IncludePlugins {
+		update(KindPlugins, func(r *Rule) { r.Enabled = &c.IncludePlugins })
+	}
+
+	if c.KeepStorage > 0 {
+		update(KindBuilds, func(r *Rule) { r.KeepStorage = &c.KeepStorage })
+	}
+	if len(c.CacheTypes) > 0 {
+		update(KindBuilds, func(r *Rule) { r.CacheTypes = c.CacheTypes })
+	}
+	if c.SharedOnly || c.ExcludeShared {
+		update(KindBuilds, func(r *Rule) { r.Shared = &c.SharedOnly })
+	}
+	if c.UnusedFor > 0 {
+		update(KindBuilds, func(r *Rule) { r.UnusedFor = &c.UnusedFor })
+	}
+	return rules
+}
+
+// EffectiveRules returns the rules of the policy with the flags and environment variables replacing their fields
+// The volume drivers of the flags add to the ones of the rule
+func (c *config) EffectiveRules() map[string]Rule {
+	rules := maps.Clone(c.Rules)
+	if rules == nil {
+		rules = make(map[string]Rule)
+	}
+	for kind, override := range c.flagRules() {
+		if override.VolumeDrivers != nil {
+			override.VolumeDrivers = append(slices.Clone(rules[kind].VolumeDrivers), override.VolumeDrivers...)
+		}
+		rules[kind] = rules[kind].merge(override)
+	}
+	return rules
+}
+
+// Effective returns the policy resulting from the policy file, profile, environment variables and flags
+func (c *config) Effective() Policy {
+	dryRun, olderThan, showSize := c.DryRun, c.OlderThan, c.ShowSize
+	settings := Settings{
+		DryRun:    &dryRun,
+		OlderThan: &olderThan,
+		ShowSize:  &showSize,
+	}
+	if c.Where != "" {
+		where := c.Where
+		settings.Where = &where
+	}
+	settings.ProtectWorkspaces = c.ProtectWorkspaces
+	return Policy{
+		Profile: Profile{
+			Settings: settings,
+			Rules:    c.EffectiveRules(),
+			Budgets:  c.Budgets,
+		},
+		DefaultProfile: c.Profile,
+	}
+}
+
+// ShowConfig displays the effective configuration and the file it was read from
+func ShowConfig() error {
+	var content strings.Builder
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(GetConfig().Effective()); err != nil {
+		return err
+	}
+
+	views.NewView().ShowConfig(GetConfig().ConfigFile, content.String())
+	return nil
+}
+
+// ValidateConfig checks a policy file, or the default one when file is empty
+func ValidateConfig(file string) error {
+	_, loaded, err := LoadPolicy(file)
+	if err != nil {
+		return err
+	}
+
+	views.NewView().ShowConfigValid(loaded)
+	return nil
+}
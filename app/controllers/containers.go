@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"docker-cleanup/app/units"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultLifecycleRetention is how long stopped containers of each lifecycle class are kept unless configured
+// Containers with a restart policy are likely meant to come back, and never started ones get a chance to be started
+var defaultLifecycleRetention = map[string]time.Duration{
+	models.LifecycleDead:          0,
+	models.LifecycleNeverStarted:  time.Hour,
+	models.LifecycleRestartPolicy: 7 * 24 * time.Hour,
+	models.LifecycleExited:        0,
+}
+
+// exitCodes returns the exit codes of the containers to select, the flag overriding the containers rule
+func (c *Controller) exitCodes() (include []int, exclude []int) {
+	rule := c.rule(KindContainers)
+	return rule.ExitCodes, rule.ExcludeExitCodes
+}
+
+// exitRetention returns how long containers are kept after exiting with a code, the flags overriding the containers rule
+func (c *Controller) exitRetention(exitCode int) time.Duration {
+	rule := c.rule(KindContainers)
+	keep := rule.KeepSucceeded
+	if exitCode != 0 {
+		keep = rule.KeepFailed
+	}
+	if keep != nil {
+		return time.Duration(*keep)
+	}
+	return 0
+}
+
+// lifecycleRetention returns how long stopped containers of a lifecycle class are kept,
+// the flag overriding the containers rule, which overrides the default
+func (c *Controller) lifecycleRetention(class string) time.Duration {
+	if retention, ok := c.rule(KindContainers).LifecycleRetention[class]; ok {
+		return time.Duration(retention)
+	}
+	return defaultLifecycleRetention[class]
+}
+
+// validateLifecycleClasses checks the classes of lifecycle retentions
+func validateLifecycleClasses(classes []string) error {
+	for _, class := range classes {
+		if !slices.Contains(models.LifecycleClasses, class) {
+			return fmt.Errorf("invalid lifecycle class %q: expected one of %s", class, strings.Join(models.LifecycleClasses, ", "))
+		}
+	}
+	return nil
+}
+
+// selectContainers keeps the containers matching the exit code filters whose retentions are over:
+// the one of their lifecycle class since they stopped, and the one of their exit code since they exited
+// Exit code filters only select containers that ran, as the others have no exit code
+// The containers kept by their lifecycle class are reported in dry-run mode
+func (c *Controller) selectContainers(containers []models.StoppedContainer) []models.StoppedContainer {
+	include, exclude := c.exitCodes()
+
+	var selected []models.StoppedContainer
+	var kept []models.Kept
+	for _, container := range containers {
+		if len(include) > 0 && (!container.Exited() || !slices.Contains(include, container.ExitCode)) {
+			continue
+		}
+		if container.Exited() && slices.Contains(exclude, container.ExitCode) {
+			continue
+		}
+		if keep := c.exitRetention(container.ExitCode); container.Exited() && keep > 0 && time.Since(container.FinishedAt) < keep {
+			continue
+		}
+		class := container.Lifecycle()
+		if keep := c.lifecycleRetention(class); keep > 0 && time.Since(container.StoppedSince()) < keep {
+			kept = append(kept, models.Kept{
+				Resource: models.ContainerResource(container),
+				Reason:   fmt.Sprintf("%s, kept for %s after it stopped", class, units.FormatDuration(keep)),
+			})
+			continue
+		}
+		selected = append(selected, container)
+	}
+
+	if GetConfig().DryRun {
+		c.view.ShowKept(kept)
+	}
+	return selected
+}
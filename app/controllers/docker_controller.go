@@ -1,32 +1,59 @@
 package controllers
 
 import (
+	"docker-cleanup/app/expr"
 	"docker-cleanup/app/models"
 	"docker-cleanup/app/views"
+	"docker-cleanup/app/workspace"
 	"fmt"
-)
+	"slices"
 
-// Config represents the configuration for the controller
-type Config struct {
-	DryRun    bool
-	OlderThan int
-	ShowSize  bool
-}
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+)
 
 // Controller manages interactions between the model and view
 type Controller struct {
-	model *models.DockerClient
-	view  *views.View
+	model    *models.DockerClient
+	view     *views.View
+	programs map[string]*expr.Program
+	// workspace holds the resources referenced by the protected workspaces, nil when there are none
+	workspace *workspace.References
+	// services holds the images and networks used by swarm services, nil until loaded
+	services *models.ServiceReferences
+	// manager is true when the daemon is a swarm manager, once the services are loaded
+	manager bool
 }
 
 // NewController creates a new Controller instance
 func NewController() (*Controller, error) {
+	var refs *workspace.References
+	if dirs := GetConfig().ProtectWorkspaces; len(dirs) > 0 {
+		var err error
+		if refs, err = workspace.Scan(dirs); err != nil {
+			return nil, fmt.Errorf("error scanning workspaces: %v", err)
+		}
+	}
+
 	dockerClient := models.NewDockerClient()
 
-	return &Controller{
-		model: dockerClient,
-		view:  views.NewView(),
-	}, nil
+	view := views.NewView()
+	view.GroupByProject = GetConfig().ByProject
+
+	c := &Controller{
+		model:     dockerClient,
+		view:      view,
+		programs:  make(map[string]*expr.Program),
+		workspace: refs,
+	}
+	// Where expressions are compiled once, so that an invalid one stops the command before anything is removed
+	for _, kind := range Kinds {
+		if _, err := c.wherePrograms(kind); err != nil {
+			dockerClient.Close()
+			return nil, fmt.Errorf("rules.%s: %v", kind, err)
+		}
+	}
+	return c, nil
 }
 
 // Close closes the Docker client
@@ -42,11 +69,12 @@ func (c *Controller) RunContainerCleanup() {
 
 	c.view.ShowTitle("Removing stopped containers...")
 
-	containers, err := c.model.GetStoppedContainers()
+	containers, err := c.model.GetStoppedContainers(c.needsSize(KindContainers))
 	if err != nil {
 		c.view.ShowError(fmt.Errorf("error retrieving containers: %v", err))
 		return
 	}
+	containers, _ = selectResources(c, KindContainers, c.selectContainers(containers), models.ContainerResource)
 
 	c.view.ShowContainers(containers, GetConfig().DryRun)
 
@@ -70,16 +98,36 @@ func (c *Controller) RunImageCleanup() {
 
 	c.view.ShowTitle("Removing unused images...")
 
-	if GetConfig().DryRun {
-		images, err := c.model.GetUnusedImages(GetConfig().OlderThan)
-		if err != nil {
-			c.view.ShowError(fmt.Errorf("error retrieving images: %v", err))
+	if !c.loadServices() {
+		return
+	}
+
+	selection := c.imageSelection()
+	images, err := c.model.GetUnusedImages(selection)
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving images: %v", err))
+		return
+	}
+	images, narrowed := selectResources(c, KindImages, images, models.ImageResource)
+	// A prune would also remove the images kept by keep-last retention
+	narrowed = narrowed || selection.KeepLast > 0
+
+	if budgets := c.budgets(); len(budgets) > 0 {
+		overBudget, ok := c.selectOverBudgetImages(budgets, images)
+		if !ok {
 			return
 		}
+		images = append(images, overBudget...)
+		// Budgets select images a prune would keep, and keep the others
+		narrowed = true
+	}
 
+	if GetConfig().DryRun {
 		c.view.ShowImages(images, true, "unused")
+	} else if narrowed {
+		c.view.ShowImagesPruneResult(c.removeImages(images), "unused")
 	} else {
-		report, err := c.model.PruneImages(GetConfig().OlderThan)
+		report, err := c.model.PruneImages(c.olderThan(KindImages))
 		if err != nil {
 			c.view.ShowError(fmt.Errorf("error removing images: %v", err))
 			return
@@ -89,6 +137,31 @@ func (c *Controller) RunImageCleanup() {
 	}
 }
 
+// selectOverBudgetImages shows the image storage budgets and returns the images selected to meet them
+func (c *Controller) selectOverBudgetImages(budgets []models.Budget, selected []image.Summary) ([]image.Summary, bool) {
+	selectedIDs := make(map[string]bool)
+	for _, img := range selected {
+		selectedIDs[img.ID] = true
+	}
+
+	groups, err := c.model.GetOverBudgetImages(budgets, selectedIDs, func(img image.Summary) bool {
+		r := models.ImageResource(img)
+		return c.excludedByRule(KindImages, r) || c.protection(r) != ""
+	})
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error computing image budgets: %v", err))
+		return nil, false
+	}
+
+	c.view.ShowBudgets(groups)
+
+	var overBudget []image.Summary
+	for _, group := range groups {
+		overBudget = append(overBudget, group.Selected...)
+	}
+	return overBudget, true
+}
+
 // RunDanglingCleanup executes the cleanup of dangling images
 func (c *Controller) RunDanglingCleanup() {
 	if GetConfig().ShowSize {
@@ -97,14 +170,21 @@ func (c *Controller) RunDanglingCleanup() {
 
 	c.view.ShowTitle("Removing dangling images...")
 
-	if GetConfig().DryRun {
-		images, err := c.model.GetDanglingImages()
-		if err != nil {
-			c.view.ShowError(fmt.Errorf("error retrieving images: %v", err))
-			return
-		}
+	if !c.loadServices() {
+		return
+	}
 
+	images, err := c.model.GetDanglingImages()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving images: %v", err))
+		return
+	}
+	images, narrowed := selectResources(c, KindDanglingImages, images, models.ImageResource)
+
+	if GetConfig().DryRun {
 		c.view.ShowImages(images, true, "dangling")
+	} else if narrowed {
+		c.view.ShowImagesPruneResult(c.removeImages(images), "dangling")
 	} else {
 		report, err := c.model.PruneDanglingImages()
 		if err != nil {
@@ -117,24 +197,46 @@ func (c *Controller) RunDanglingCleanup() {
 }
 
 // RunVolumeCleanup executes the cleanup of unused volumes
-func (c *Controller) RunVolumeCleanup() {
-
+// When names are given, only these volumes are removed, whatever the age, name and database rules
+func (c *Controller) RunVolumeCleanup(names []string) {
 	if GetConfig().ShowSize {
 		c.ShowDiskUsage()
 	}
 
 	c.view.ShowTitle("Removing unused volumes...")
 
-	if GetConfig().DryRun {
-		volumes, err := c.model.GetUnusedVolumes()
-		if err != nil {
-			c.view.ShowError(fmt.Errorf("error retrieving volumes: %v", err))
-			return
-		}
+	includeNamed := c.includeNamedVolumes() || len(names) > 0
+	volumes, err := c.model.GetUnusedVolumes(includeNamed)
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving volumes: %v", err))
+		return
+	}
+	volumes, narrowed := c.selectVolumes(volumes, names)
+	c.readLastWrites(volumes, len(names) == 0)
+	if len(names) == 0 {
+		var selectionNarrowed bool
+		volumes, selectionNarrowed = selectResources(c, KindVolumes, volumes, models.UnusedVolumeResource)
+		narrowed = narrowed || selectionNarrowed
+	}
+	// Older daemons cannot prune anonymous volumes only, and a prune misses the ones without the anonymous label
+	narrowed = narrowed || !includeNamed && !c.model.PrunesAnonymousVolumesOnly()
+	narrowed = narrowed || !includeNamed && slices.ContainsFunc(volumes, func(vol models.UnusedVolume) bool {
+		return !models.IsLabeledAnonymousVolume(vol.Volume)
+	})
+	// A prune would remove the volumes without backing them up
+	narrowed = narrowed || GetConfig().BackupDir != ""
 
+	c.view.ShowRemoteVolumesWarning(volumes)
+
+	if GetConfig().DryRun {
 		c.view.ShowVolumes(volumes, true)
+		if GetConfig().BackupDir != "" && len(volumes) > 0 {
+			c.view.ShowVolumesBackupPlan(GetConfig().BackupDir)
+		}
+	} else if narrowed {
+		c.view.ShowVolumesPruneResult(c.removeVolumes(volumes))
 	} else {
-		report, err := c.model.PruneVolumes()
+		report, err := c.model.PruneVolumes(includeNamed)
 		if err != nil {
 			c.view.ShowError(fmt.Errorf("error removing volumes: %v", err))
 			return
@@ -152,14 +254,29 @@ func (c *Controller) RunNetworkCleanup() {
 
 	c.view.ShowTitle("Removing unused networks...")
 
-	if GetConfig().DryRun {
-		networks, err := c.model.GetUnusedNetworks()
-		if err != nil {
-			c.view.ShowError(fmt.Errorf("error retrieving networks: %v", err))
-			return
+	if !c.loadServices() {
+		return
+	}
+
+	networks, attachments, err := c.model.GetUnusedNetworks(c.ignoreStoppedAttachments())
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving networks: %v", err))
+		return
+	}
+	networks, narrowed := selectResources(c, KindNetworks, networks, models.NetworkResource)
+
+	// The daemon prunes networks stopped containers are attached to, so they are kept by removing the others one by one
+	if len(attachments) > 0 {
+		narrowed = true
+		if GetConfig().DryRun {
+			c.view.ShowKept(c.stoppedAttachments(attachments))
 		}
+	}
 
+	if GetConfig().DryRun {
 		c.view.ShowNetworks(networks, true)
+	} else if narrowed {
+		c.view.ShowNetworksPruneResult(c.removeNetworks(networks))
 	} else {
 		report, err := c.model.PruneNetworks()
 		if err != nil {
@@ -173,17 +290,40 @@ func (c *Controller) RunNetworkCleanup() {
 
 // RunAllCleanup executes the cleanup of all Docker resources
 func (c *Controller) RunAllCleanup() {
-	c.RunContainerCleanup()
-	fmt.Println()
-	c.RunDanglingCleanup()
-	fmt.Println()
-	c.RunImageCleanup()
-	fmt.Println()
-	c.RunVolumeCleanup()
-	fmt.Println()
-	c.RunNetworkCleanup()
-	fmt.Println()
-	c.RunBuildsCleanup()
+	steps := []struct {
+		kind string
+		run  func()
+		// swarm steps only run on swarm managers
+		swarm bool
+		// optIn steps only run when requested with a flag or enabled by the policy
+		optIn bool
+	}{
+		{KindExpired, c.RunExpiredCleanup, false, false},
+		{KindContainers, c.RunContainerCleanup, false, false},
+		{KindDanglingImages, c.RunDanglingCleanup, false, false},
+		{KindImages, c.RunImageCleanup, false, false},
+		{KindVolumes, func() { c.RunVolumeCleanup(nil) }, false, false},
+		{KindNetworks, c.RunNetworkCleanup, false, false},
+		{KindBuilds, c.RunBuildsCleanup, false, false},
+		{KindConfigs, c.RunConfigCleanup, true, false},
+		{KindSecrets, c.RunSecretCleanup, true, false},
+		{KindPlugins, c.RunPluginCleanup, false, true},
+	}
+
+	first := true
+	for _, step := range steps {
+		if step.optIn && !c.optedIn(step.kind) || !step.optIn && !c.enabled(step.kind) {
+			continue
+		}
+		if step.swarm && !(c.loadServices() && c.manager) {
+			continue
+		}
+		if !first {
+			fmt.Println()
+		}
+		first = false
+		step.run()
+	}
 
 	c.view.ShowCleanupComplete()
 }
@@ -207,16 +347,28 @@ func (c *Controller) RunBuildsCleanup() {
 
 	c.view.ShowTitle("Removing Docker builds...")
 
-	if GetConfig().DryRun {
-		builds, err := c.model.GetUnusedBuilds()
-		if err != nil {
-			c.view.ShowError(fmt.Errorf("error retrieving builds: %v", err))
-			return
-		}
+	selection := c.buildSelection()
+	builds, err := c.model.GetUnusedBuilds(selection)
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving builds: %v", err))
+		return
+	}
+	builds, narrowed := selectResources(c, KindBuilds, builds, models.BuildResource)
+	// Prune filters select records by last use, while the age setting is compared to their creation
+	narrowed = narrowed || c.olderThan(KindBuilds) > 0 || !selection.Prunable()
+
+	if keep := c.keepStorage(); keep > 0 {
+		selected := len(builds)
+		builds = models.KeepBuildStorage(builds, keep)
+		narrowed = narrowed || len(builds) != selected
+	}
 
+	if GetConfig().DryRun {
 		c.view.ShowBuilds(builds, true)
+	} else if narrowed {
+		c.removeBuilds(builds)
 	} else {
-		report, err := c.model.PruneBuilds(GetConfig().OlderThan)
+		report, err := c.model.PruneBuilds(selection)
 		if err != nil {
 			c.view.ShowError(fmt.Errorf("error removing builds: %v", err))
 			return
@@ -225,3 +377,20 @@ func (c *Controller) RunBuildsCleanup() {
 		c.view.ShowBuildsPruneResult(report)
 	}
 }
+
+// RunBuildsReport displays the build cache grouped by record type and build step
+func (c *Controller) RunBuildsReport() {
+	c.view.ShowTitle("Build cache report:")
+
+	usage, err := c.model.GetDiskUsage()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving builds: %v", err))
+		return
+	}
+
+	builds := make([]types.BuildCache, 0, len(usage.BuildCache))
+	for _, build := range usage.BuildCache {
+		builds = append(builds, *build)
+	}
+	c.view.ShowBuildsReport(models.GroupBuilds(builds))
+}
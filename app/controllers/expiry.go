@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DefaultStopTimeout is how long expired running containers are given to stop before they are killed
+const DefaultStopTimeout = 10 * time.Second
+
+// stopTimeout returns how long expired running containers are given to stop, the flag overriding the expired rule
+func (c *Controller) stopTimeout() time.Duration {
+	if timeout := c.rule(KindExpired).StopTimeout; timeout != nil {
+		return time.Duration(*timeout)
+	}
+	return DefaultStopTimeout
+}
+
+// RunExpiredCleanup removes the containers, networks, volumes and images whose TTL or expiry label has passed
+// Running containers are stopped gracefully first, and the resources in use by swarm services or workspaces are kept
+func (c *Controller) RunExpiredCleanup() {
+	c.view.ShowTitle("Removing expired resources...")
+
+	if !c.loadServices() {
+		return
+	}
+
+	resources, err := c.model.GetExpiringResources()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving labeled resources: %v", err))
+		return
+	}
+
+	now := time.Now()
+	var expired []models.ExpiringResource
+	var volumes []models.UnusedVolume
+	var kept []models.Kept
+	for _, r := range resources {
+		if r.Err != nil {
+			c.view.ShowError(fmt.Errorf("%s %s: %v", r.Kind, r.Name, r.Err))
+			continue
+		}
+		if !r.Expired(now) {
+			continue
+		}
+		if reason := c.protection(r.Resource); reason != "" {
+			kept = append(kept, models.Kept{Resource: r.Resource, Reason: reason})
+			continue
+		}
+		if r.Volume != nil {
+			volumes = append(volumes, models.DescribeVolume(*r.Volume))
+		}
+		expired = append(expired, r)
+	}
+
+	if GetConfig().DryRun {
+		c.view.ShowKept(kept)
+	}
+	// Expired volumes are kept, and backed up, like the volumes of any other cleanup
+	selected, _ := c.selectVolumes(volumes, nil)
+	selectedVolumes := make(map[string]models.UnusedVolume)
+	for _, vol := range selected {
+		selectedVolumes[vol.Name] = vol
+	}
+	expired = slices.DeleteFunc(expired, func(r models.ExpiringResource) bool {
+		_, ok := selectedVolumes[r.Name]
+		return r.Volume != nil && !ok
+	})
+	c.view.ShowRemoteVolumesWarning(selected)
+	c.view.ShowExpired(expired, GetConfig().DryRun)
+
+	if GetConfig().DryRun {
+		if GetConfig().BackupDir != "" && len(selected) > 0 {
+			c.view.ShowVolumesBackupPlan(GetConfig().BackupDir)
+		}
+		return
+	}
+	for _, r := range expired {
+		if r.Volume != nil {
+			// removeVolumes reports its own errors
+			if len(c.removeVolumes([]models.UnusedVolume{selectedVolumes[r.Name]}).VolumesDeleted) > 0 {
+				c.view.ShowExpiredRemoved(r)
+			}
+			continue
+		}
+		if err := c.removeExpired(r); err != nil {
+			c.view.ShowError(fmt.Errorf("error removing %s %s: %v", r.Kind, r.Name, err))
+			continue
+		}
+		c.view.ShowExpiredRemoved(r)
+	}
+}
+
+// removeExpired removes an expired container, network or image, stopping it first when it is a running container
+func (c *Controller) removeExpired(r models.ExpiringResource) error {
+	switch r.Kind {
+	case "container":
+		if r.Running() {
+			if err := c.model.StopContainer(r.ID, c.stopTimeout()); err != nil {
+				return err
+			}
+		}
+		return c.model.RemoveContainer(r.ID)
+	case "network":
+		return c.model.RemoveNetwork(r.ID)
+	case "image":
+		_, err := c.model.RemoveImage(r.ID, r.Aliases)
+		return err
+	}
+	return fmt.Errorf("unsupported kind")
+}
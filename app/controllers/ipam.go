@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+	"slices"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+// RunIPAMReport displays the subnets of the networks, the address pool each of them consumes and the subnets left
+func (c *Controller) RunIPAMReport() {
+	c.view.ShowTitle("Network address pools:")
+
+	usage, err := c.model.GetIPAMUsage()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving address pools: %v", err))
+		return
+	}
+
+	c.view.ShowIPAMReport(usage)
+}
+
+// RunSubnetReclaim removes the oldest unused bridge networks until the address pools have the requested number of free subnets
+// Networks that free no subnet, being outside the pools or sharing their subnets with others, are never removed
+func (c *Controller) RunSubnetReclaim(free int) {
+	c.view.ShowTitle(fmt.Sprintf("Freeing %d subnets in the network address pools...", free))
+
+	usage, err := c.model.GetIPAMUsage()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving address pools: %v", err))
+		return
+	}
+	if usage.Free() >= free {
+		c.view.ShowSubnetsFree(usage.Free(), free)
+		return
+	}
+
+	if !c.loadServices() {
+		return
+	}
+	networks, _, err := c.model.GetUnusedNetworks(c.ignoreStoppedAttachments())
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving networks: %v", err))
+		return
+	}
+	networks, _ = selectResources(c, KindNetworks, networks, models.NetworkResource)
+
+	slices.SortStableFunc(networks, func(a, b network.Summary) int { return a.Created.Compare(b.Created) })
+	var reclaimed []network.Summary
+	for _, net := range networks {
+		if usage.Free() >= free {
+			break
+		}
+		if net.Driver != "bridge" {
+			continue
+		}
+		if usage.Release(net.ID) > 0 {
+			reclaimed = append(reclaimed, net)
+		}
+	}
+
+	if GetConfig().DryRun {
+		c.view.ShowNetworks(reclaimed, true)
+		c.view.ShowSubnetsFree(usage.Free(), free)
+		return
+	}
+
+	c.view.ShowNetworksPruneResult(c.removeNetworks(reclaimed))
+	// Networks may have failed to be removed or been created meanwhile
+	if usage, err = c.model.GetIPAMUsage(); err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving address pools: %v", err))
+		return
+	}
+	c.view.ShowSubnetsFree(usage.Free(), free)
+}
@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+)
+
+// RunPluginCleanup executes the cleanup of disabled and unused managed plugins
+func (c *Controller) RunPluginCleanup() {
+	c.view.ShowTitle("Removing unused plugins...")
+
+	plugins, err := c.model.GetUnusedPlugins()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving plugins: %v", err))
+		return
+	}
+	plugins, _ = selectResources(c, KindPlugins, plugins, models.PluginResource)
+
+	c.view.ShowPlugins(plugins, GetConfig().DryRun)
+
+	if !GetConfig().DryRun && len(plugins) > 0 {
+		var removed []models.UnusedPlugin
+		for _, plugin := range plugins {
+			if err := c.model.RemovePlugin(plugin.Plugin); err != nil {
+				c.view.ShowError(fmt.Errorf("error removing plugin %s: %v", plugin.Name, err))
+				continue
+			}
+			removed = append(removed, plugin)
+		}
+		c.view.ShowPluginsRemoved(removed)
+	}
+}
@@ -0,0 +1,517 @@
+package controllers
+
+import (
+	"bytes"
+	"docker-cleanup/app/models"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resource kinds used as keys of the policy rules
+const (
+	KindContainers     = "containers"
+	KindImages         = "images"
+	KindDanglingImages = "dangling_images"
+	KindVolumes        = "volumes"
+	KindNetworks       = "networks"
+	KindBuilds         = "builds"
+	KindConfigs        = "configs"
+	KindSecrets        = "secrets"
+	KindPlugins        = "plugins"
+	KindServices       = "services"
+	KindBuilders       = "builders"
+	KindExpired        = "expired"
+	KindProjects       = "projects"
+)
+
+// Kinds lists every resource kind a rule can be written for
+var Kinds = []string{KindContainers, KindDanglingImages, KindImages, KindVolumes, KindNetworks, KindBuilds, KindConfigs, KindSecrets, KindPlugins, KindServices, KindBuilders, KindExpired, KindProjects}
+
+// Settings holds the global settings that can also be given as flags or environment variables
+type Settings struct {
+	DryRun    *bool     `yaml:"dry_run,omitempty"`
+	OlderThan *Duration `yaml:"older_than,omitempty"`
+	ShowSize  *bool     `yaml:"show_size,omitempty"`
+	Where     *string   `yaml:"where,omitempty"`
+	// ProtectWorkspaces lists directories whose Compose files and Dockerfiles protect the resources they reference
+	ProtectWorkspaces []string `yaml:"protect_workspaces,omitempty"`
+}
+
+// Values returns the settings present in the file, keyed by the name of their flag
+// Settings holding a list have one value per element
+func (s Settings) Values() map[string][]string {
+	values := make(map[string][]string)
+	if s.DryRun != nil {
+		values["dry-run"] = []string{strconv.FormatBool(*s.DryRun)}
+	}
+	if s.OlderThan != nil {
+		values["older-than"] = []string{s.OlderThan.String()}
+	}
+	if s.ShowSize != nil {
+		values["show-size"] = []string{strconv.FormatBool(*s.ShowSize)}
+	}
+	if s.Where != nil {
+		values["where"] = []string{*s.Where}
+	}
+	if s.ProtectWorkspaces != nil {
+		values["protect-workspace"] = s.ProtectWorkspaces
+	}
+	return values
+}
+
+// merge returns the settings with the ones set in override replacing them
+func (s Settings) merge(override Settings) Settings {
+	if override.DryRun != nil {
+		s.DryRun = override.DryRun
+	}
+	if override.OlderThan != nil {
+		s.OlderThan = override.OlderThan
+	}
+	if override.ShowSize != nil {
+		s.ShowSize = override.ShowSize
+	}
+	if override.Where != nil {
+		s.Where = override.Where
+	}
+	if override.ProtectWorkspaces != nil {
+		s.ProtectWorkspaces = override.ProtectWorkspaces
+	}
+	return s
+}
+
+// Rule describes how the resources of one kind are selected for cleanup
+type Rule struct {
+	// Enabled controls whether the kind is cleaned by the all command
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// OlderThan overrides the global age for this kind
+	OlderThan *Duration `yaml:"older_than,omitempty"`
+	// Filters restricts the selection, e.g. "label=env=ci", "label!=keep" or "name=tmp-*"
+	Filters []string `yaml:"filters,omitempty"`
+	// Keep lists name patterns of resources that are never removed
+	Keep []string `yaml:"keep,omitempty"`
+	// MinSize and MaxSize bound the size of the selected resources
+	MinSize *ByteSize `yaml:"min_size,omitempty"`
+	MaxSize *ByteSize `yaml:"max_size,omitempty"`
+	// Where is an expression the resources must satisfy, see the expr package
+	Where string `yaml:"where,omitempty"`
+
+	// KeepLast keeps the N most recent tags of each image repository
+	KeepLast *int `yaml:"keep_last,omitempty"`
+	// KeepLastOrder ranks tags by "time" or "semver"
+	KeepLastOrder string `yaml:"keep_last_order,omitempty"`
+	// KeepLastRepositories restricts keep_last to the repositories matching these patterns
+	KeepLastRepositories []string `yaml:"keep_last_repositories,omitempty"`
+
+	// ExitCodes restricts the selection to the containers that exited with these codes
+	ExitCodes []int `yaml:"exit_codes,omitempty"`
+	// ExcludeExitCodes never selects the containers that exited with these codes
+	ExcludeExitCodes []int `yaml:"exclude_exit_codes,omitempty"`
+	// KeepSucceeded and KeepFailed are the minimum times since containers exited with code 0, or another code
+	KeepSucceeded *Duration `yaml:"keep_succeeded,omitempty"`
+	KeepFailed    *Duration `yaml:"keep_failed,omitempty"`
+	// LifecycleRetention is the minimum time since stopped containers of each lifecycle class stopped
+	LifecycleRetention map[string]Duration `yaml:"lifecycle_retention,omitempty"`
+
+	// UnwrittenFor is the minimum time since the content of the resources was last written
+	UnwrittenFor *Duration `yaml:"unwritten_for,omitempty"`
+
+	// IncludeNamed also selects named volumes, only anonymous ones being selected otherwise
+	IncludeNamed *bool `yaml:"include_named,omitempty"`
+
+	// VolumeDrivers also selects the volumes of these drivers, only local volumes being selected otherwise
+	VolumeDrivers []string `yaml:"volume_drivers,omitempty"`
+	// IncludeRemote also selects volumes whose data may live on a remote host: other drivers than local and network shares
+	IncludeRemote *bool `yaml:"include_remote,omitempty"`
+
+	// IncludeDatabases also selects volumes holding the data files of a database
+	IncludeDatabases *bool `yaml:"include_databases,omitempty"`
+
+	// IgnoreStoppedAttachments also selects networks only stopped containers are attached to
+	IgnoreStoppedAttachments *bool `yaml:"ignore_stopped_attachments,omitempty"`
+
+	// StopTimeout is how long expired running containers are given to stop before they are killed
+	StopTimeout *Duration `yaml:"stop_timeout,omitempty"`
+
+	// KeepStorage keeps up to this many bytes of the most recently used build cache
+	KeepStorage *ByteSize `yaml:"keep_storage,omitempty"`
+	// CacheTypes restricts the selection to these build cache record types
+	CacheTypes []string `yaml:"cache_types,omitempty"`
+	// Shared selects only shared build cache records when true and only exclusive ones when false
+	Shared *bool `yaml:"shared,omitempty"`
+	// UnusedFor is the minimum time since build cache records were last used
+	UnusedFor *Duration `yaml:"unused_for,omitempty"`
+}
+
+// kindFields are the rule fields that only apply to some kinds, by YAML key
+var kindFields = []struct {
+	key   string
+	set   func(Rule) bool
+	kinds []string
+}{
+	{"keep_last", func(r Rule) bool { return r.KeepLast != nil }, []string{KindImages}},
+	{"keep_last_order", func(r Rule) bool { return r.KeepLastOrder != "" }, []string{KindImages}},
+	{"keep_last_repositories", func(r Rule) bool { return r.KeepLastRepositories != nil }, []string{KindImages}},
+	{"exit_codes", func(r Rule) bool { return r.ExitCodes != nil }, []string{KindContainers}},
+	{"exclude_exit_codes", func(r Rule) bool { return r.ExcludeExitCodes != nil }, []string{KindContainers}},
+	{"keep_succeeded", func(r Rule) bool { return r.KeepSucceeded != nil }, []string{KindContainers}},
+	{"keep_failed", func(r Rule) bool { return r.KeepFailed != nil }, []string{KindContainers}},
+	{"lifecycle_retention", func(r Rule) bool { return r.LifecycleRetention != nil }, []string{KindContainers}},
+	{"unwritten_for", func(r Rule) bool { return r.UnwrittenFor != nil }, []string{KindVolumes}},
+	{"include_named", func(r Rule) bool { return r.IncludeNamed != nil }, []string{KindVolumes}},
+	{"volume_drivers", func(r Rule) bool { return r.VolumeDrivers != nil }, []string{KindVolumes}},
+	{"include_remote", func(r Rule) bool { return r.IncludeRemote != nil }, []string{KindVolumes}},
+	{"include_databases", func(r Rule) bool { return r.IncludeDatabases != nil }, []string{KindVolumes}},
+	{"ignore_stopped_attachments", func(r Rule) bool { return r.IgnoreStoppedAttachments != nil }, []string{KindNetworks}},
+	{"stop_timeout", func(r Rule) bool { return r.StopTimeout != nil }, []string{KindExpired}},
+	{"keep_storage", func(r Rule) bool { return r.KeepStorage != nil }, []string{KindBuilds}},
+	{"cache_types", func(r Rule) bool { return r.CacheTypes != nil }, []string{KindBuilds}},
+	{"shared", func(r Rule) bool { return r.Shared != nil }, []string{KindBuilds}},
+	{"unused_for", func(r Rule) bool { return r.UnusedFor != nil }, []string{KindBuilds}},
+}
+
+// Validate checks that the fields of the rule apply to its kind, and that its filters and patterns can be used
+func (r Rule) Validate(kind string) error {
+	for _, field := range kindFields {
+		if field.set(r) && !slices.Contains(field.kinds, kind) {
+			return fmt.Errorf("%s only applies to %s", field.key, strings.Join(field.kinds, ", "))
+		}
+	}
+	for _, f := range r.Filters {
+		if _, err := parseFilter(f); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range r.Keep {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid keep pattern %q: %v", pattern, err)
+		}
+	}
+	if r.MinSize != nil && r.MaxSize != nil && *r.MinSize > *r.MaxSize {
+		return fmt.Errorf("min_size %s is greater than max_size %s", r.MinSize, r.MaxSize)
+	}
+	if r.Where != "" {
+		if _, err := compileWhere(r.Where); err != nil {
+			return fmt.Errorf("where: %v", err)
+		}
+	}
+	if r.KeepLast != nil && *r.KeepLast < 0 {
+		return fmt.Errorf("keep_last must not be negative")
+	}
+	if err := validateKeepLast(r.KeepLastOrder, r.KeepLastRepositories); err != nil {
+		return err
+	}
+	if err := validateLifecycleClasses(slices.Collect(maps.Keys(r.LifecycleRetention))); err != nil {
+		return fmt.Errorf("lifecycle_retention: %v", err)
+	}
+	if r.KeepStorage != nil && *r.KeepStorage < 0 {
+		return fmt.Errorf("keep_storage must not be negative")
+	}
+	return validateCacheTypes(r.CacheTypes)
+}
+
+// merge returns the rule with the fields set in override replacing its own
+func (r Rule) merge(override Rule) Rule {
+	if override.Enabled != nil {
+		r.Enabled = override.Enabled
+	}
+	if override.OlderThan != nil {
+		r.OlderThan = override.OlderThan
+	}
+	if override.Filters != nil {
+		r.Filters = override.Filters
+	}
+	if override.Keep != nil {
+		r.Keep = override.Keep
+	}
+	if override.MinSize != nil {
+		r.MinSize = override.MinSize
+	}
+	if override.MaxSize != nil {
+		r.MaxSize = override.MaxSize
+	}
+	if override.Where != "" {
+		r.Where = override.Where
+	}
+	if override.KeepLast != nil {
+		r.KeepLast = override.KeepLast
+	}
+	if override.KeepLastOrder != "" {
+		r.KeepLastOrder = override.KeepLastOrder
+	}
+	if override.KeepLastRepositories != nil {
+		r.KeepLastRepositories = override.KeepLastRepositories
+	}
+	if override.ExitCodes != nil {
+		r.ExitCodes = override.ExitCodes
+	}
+	if override.ExcludeExitCodes != nil {
+		r.ExcludeExitCodes = override.ExcludeExitCodes
+	}
+	if override.KeepSucceeded != nil {
+		r.KeepSucceeded = override.KeepSucceeded
+	}
+	if override.KeepFailed != nil {
+		r.KeepFailed = override.KeepFailed
+	}
+	if override.LifecycleRetention != nil {
+		retention := make(map[string]Duration)
+		for class, d := range r.LifecycleRetention {
+			retention[class] = d
+		}
+		for class, d := range override.LifecycleRetention {
+			retention[class] = d
+		}
+		r.LifecycleRetention = retention
+	}
+	if override.UnwrittenFor != nil {
+		r.UnwrittenFor = override.UnwrittenFor
+	}
+	if override.IncludeNamed != nil {
+		r.IncludeNamed = override.IncludeNamed
+	}
+	if override.VolumeDrivers != nil {
+		r.VolumeDrivers = override.VolumeDrivers
+	}
+	if override.IncludeRemote != nil {
+		r.IncludeRemote = override.IncludeRemote
+	}
+	if override.IncludeDatabases != nil {
+		r.IncludeDatabases = override.IncludeDatabases
+	}
+	if override.IgnoreStoppedAttachments != nil {
+		r.IgnoreStoppedAttachments = override.IgnoreStoppedAttachments
+	}
+	if override.StopTimeout != nil {
+		r.StopTimeout = override.StopTimeout
+	}
+	if override.KeepStorage != nil {
+		r.KeepStorage = override.KeepStorage
+	}
+	if override.CacheTypes != nil {
+		r.CacheTypes = override.CacheTypes
+	}
+	if override.Shared != nil {
+		r.Shared = override.Shared
+	}
+	if override.UnusedFor != nil {
+		r.UnusedFor = override.UnusedFor
+	}
+	return r
+}
+
+// validateCacheTypes checks that build cache record types are known
+func validateCacheTypes(cacheTypes []string) error {
+	for _, t := range cacheTypes {
+		if !slices.Contains(models.CacheTypes, t) {
+			return fmt.Errorf("invalid cache type %q: expected one of %s", t, strings.Join(models.CacheTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// validateKeepLast checks the ordering and repository patterns of keep-last retention
+func validateKeepLast(order string, repositories []string) error {
+	if order != "" && order != models.OrderTime && order != models.OrderSemver {
+		return fmt.Errorf("invalid keep-last order %q: expected %s or %s", order, models.OrderTime, models.OrderSemver)
+	}
+	for _, pattern := range repositories {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid repository pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Profile bundles settings and rules that are applied together
+type Profile struct {
+	Settings `yaml:",inline"`
+	// Kinds restricts the all command to the listed kinds
+	Kinds []string        `yaml:"kinds,omitempty"`
+	Rules map[string]Rule `yaml:"rules,omitempty"`
+	// Budgets limits the storage used by the images of the repositories matching each pattern
+	Budgets map[string]ByteSize `yaml:"budgets,omitempty"`
+}
+
+// Validate checks that every kind and rule of the profile is known and well formed
+func (p Profile) Validate() error {
+	var errs []error
+	if p.Where != nil {
+		if _, err := compileWhere(*p.Where); err != nil {
+			errs = append(errs, fmt.Errorf("where: %v", err))
+		}
+	}
+	for _, kind := range p.Kinds {
+		if !slices.Contains(Kinds, kind) {
+			errs = append(errs, fmt.Errorf("kinds: unknown kind %q", kind))
+		}
+	}
+	for kind, rule := range p.Rules {
+		if !slices.Contains(Kinds, kind) {
+			errs = append(errs, fmt.Errorf("rules: unknown kind %q", kind))
+			continue
+		}
+		if err := rule.Validate(kind); err != nil {
+			errs = append(errs, fmt.Errorf("rules.%s: %v", kind, err))
+		}
+	}
+	for pattern := range p.Budgets {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("budgets: invalid repository pattern %q: %v", pattern, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// merge returns the profile with the settings and rules of override applied on top of it
+func (p Profile) merge(override Profile) Profile {
+	merged := Profile{
+		Settings: p.Settings.merge(override.Settings),
+		Kinds:    p.Kinds,
+		Rules:    make(map[string]Rule),
+		Budgets:  make(map[string]ByteSize),
+	}
+	if override.Kinds != nil {
+		merged.Kinds = override.Kinds
+	}
+	for kind, rule := range p.Rules {
+		merged.Rules[kind] = rule
+	}
+	for kind, rule := range override.Rules {
+		merged.Rules[kind] = merged.Rules[kind].merge(rule)
+	}
+	for pattern, limit := range p.Budgets {
+		merged.Budgets[pattern] = limit
+	}
+	for pattern, limit := range override.Budgets {
+		merged.Budgets[pattern] = limit
+	}
+	return merged
+}
+
+// Policy is the content of a policy configuration file
+type Policy struct {
+	Profile `yaml:",inline"`
+	// DefaultProfile is the profile used when none is given with --profile
+	DefaultProfile string             `yaml:"profile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Validate checks the rules of the policy and of each of its profiles
+func (p Policy) Validate() error {
+	var errs []error
+	if err := p.Profile.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	for name, profile := range p.Profiles {
+		if err := profile.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("profiles.%s: %v", name, err))
+		}
+	}
+	if p.DefaultProfile != "" {
+		if _, err := p.lookup(p.DefaultProfile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// lookup finds a profile in the policy file, then among the built-in ones
+func (p Policy) lookup(name string) (Profile, error) {
+	if profile, ok := p.Profiles[name]; ok {
+		return profile, nil
+	}
+	if profile, ok := BuiltinProfiles()[name]; ok {
+		return profile, nil
+	}
+	return Profile{}, fmt.Errorf("unknown profile %q", name)
+}
+
+// Resolve returns the settings and rules of the policy with the named profile applied
+// The default profile of the policy is used when name is empty
+func (p Policy) Resolve(name string) (Profile, error) {
+	if name == "" {
+		name = p.DefaultProfile
+	}
+
+	resolved := Profile{}.merge(p.Profile)
+	if name != "" {
+		profile, err := p.lookup(name)
+		if err != nil {
+			return resolved, err
+		}
+		resolved = resolved.merge(profile)
+	}
+
+	if resolved.Kinds != nil {
+		for _, kind := range Kinds {
+			enabled := slices.Contains(resolved.Kinds, kind)
+			resolved.Rules[kind] = resolved.Rules[kind].merge(Rule{Enabled: &enabled})
+		}
+	}
+	return resolved, nil
+}
+
+// DefaultConfigPaths returns the locations searched for a policy file, in order
+func DefaultConfigPaths() []string {
+	var paths []string
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			configHome = filepath.Join(home, ".config")
+		}
+	}
+	if configHome != "" {
+		paths = append(paths, filepath.Join(configHome, "docker-cleanup", "config.yaml"))
+	}
+
+	return append(paths, "/etc/docker-cleanup/config.yaml")
+}
+
+// ReadPolicy parses and validates a policy file
+func ReadPolicy(file string) (Policy, error) {
+	var policy Policy
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return policy, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil && !errors.Is(err, io.EOF) {
+		return policy, fmt.Errorf("%s: %v", file, err)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("%s: %v", file, err)
+	}
+	return policy, nil
+}
+
+// LoadPolicy reads the given policy file, or the first default one that exists when file is empty
+// It returns the path of the file that was read, which is empty when no default file exists
+func LoadPolicy(file string) (Policy, string, error) {
+	if file != "" {
+		policy, err := ReadPolicy(file)
+		return policy, file, err
+	}
+
+	for _, candidate := range DefaultConfigPaths() {
+		if _, err := os.Stat(candidate); err == nil {
+			policy, err := ReadPolicy(candidate)
+			return policy, candidate, err
+		}
+	}
+	return Policy{}, "", nil
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	_ "embed"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed profiles.yaml
+var builtinProfiles []byte
+
+// BuiltinProfiles returns the profiles shipped with the binary
+func BuiltinProfiles() map[string]Profile {
+	profiles := make(map[string]Profile)
+	if err := yaml.Unmarshal(builtinProfiles, &profiles); err != nil {
+		panic(fmt.Errorf("invalid built-in profiles: %v", err))
+	}
+	return profiles
+}
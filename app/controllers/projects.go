@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// RunProjectsList displays the Docker Compose projects and their state
+func (c *Controller) RunProjectsList() {
+	c.view.ShowTitle("Docker Compose projects:")
+
+	projects, err := c.model.GetProjects()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving projects: %v", err))
+		return
+	}
+
+	c.view.ShowProjects(projects)
+}
+
+// RunProjectsCleanup removes stale Docker Compose projects
+// When names are given only these projects are considered, otherwise every stopped project that is
+// orphaned or inactive for longer than the age of the projects kind, 7 days when unset, is.
+// Projects with a running, paused or restarting container are never touched.
+func (c *Controller) RunProjectsCleanup(names []string) {
+	if GetConfig().ShowSize {
+		c.ShowDiskUsage()
+	}
+
+	c.view.ShowTitle("Removing stale Docker Compose projects...")
+
+	if !c.loadServices() {
+		return
+	}
+
+	projects, err := c.model.GetProjects()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving projects: %v", err))
+		return
+	}
+
+	for _, name := range names {
+		if !slices.ContainsFunc(projects, func(p models.Project) bool { return p.Name == name }) {
+			c.view.ShowError(fmt.Errorf("project %s not found", name))
+		}
+	}
+
+	olderThan := c.olderThan(KindProjects)
+	var stale []models.Project
+	for _, project := range projects {
+		named := slices.Contains(names, project.Name)
+		if len(names) > 0 && !named {
+			continue
+		}
+		if project.Running > 0 {
+			if named {
+				c.view.ShowProjectSkipped(project, "it has running, paused or restarting containers")
+			}
+			continue
+		}
+		// Orphaned projects are removed whatever their age, their checkout is gone
+		if !named && project.Orphaned == "" && time.Since(project.LastActivity) < olderThan {
+			continue
+		}
+		stale = append(stale, project)
+	}
+
+	var kept []models.Kept
+	for i := range stale {
+		kept = append(kept, c.protectProject(&stale[i])...)
+	}
+	if GetConfig().DryRun {
+		c.view.ShowKept(kept)
+	}
+
+	// Project volumes follow the rules of the volumes command: remote and database volumes are kept unless
+	// opted in, and the others are backed up first with a backup directory
+	includeVolumes := GetConfig().IncludeProjectVolumes
+	volumes := make(map[string][]models.UnusedVolume)
+	var backedUp int
+	if includeVolumes {
+		for i := range stale {
+			var described []models.UnusedVolume
+			for _, vol := range stale[i].Volumes {
+				described = append(described, models.DescribeVolume(vol))
+			}
+			selected, _ := c.selectVolumes(described, nil)
+			stale[i].Volumes = nil
+			for _, vol := range selected {
+				stale[i].Volumes = append(stale[i].Volumes, vol.Volume)
+			}
+			volumes[stale[i].Name] = selected
+			backedUp += len(selected)
+			c.view.ShowRemoteVolumesWarning(selected)
+		}
+	}
+	c.view.ShowStaleProjects(stale, GetConfig().DryRun, includeVolumes)
+
+	if GetConfig().DryRun {
+		if GetConfig().BackupDir != "" && backedUp > 0 {
+			c.view.ShowVolumesBackupPlan(GetConfig().BackupDir)
+		}
+		return
+	}
+	for _, project := range stale {
+		c.removeProject(project, volumes[project.Name])
+	}
+}
+
+// protectProject leaves out the containers, networks and volumes of a project that swarm services use or the
+// protected workspaces reference, and returns them with the reason why
+func (c *Controller) protectProject(project *models.Project) []models.Kept {
+	var kept []models.Kept
+	project.Containers = unprotected(c, project.Containers, func(container types.Container) models.Resource {
+		return models.ContainerResource(models.StoppedContainer{Container: container})
+	}, &kept)
+	project.Networks = unprotected(c, project.Networks, models.NetworkResource, &kept)
+	project.Volumes = unprotected(c, project.Volumes, models.VolumeResource, &kept)
+	return kept
+}
+
+// unprotected returns the items that are not protected, adding the others to kept
+func unprotected[T any](c *Controller, items []T, resource func(T) models.Resource, kept *[]models.Kept) []T {
+	var result []T
+	for _, item := range items {
+		r := resource(item)
+		if reason := c.protection(r); reason != "" {
+			*kept = append(*kept, models.Kept{Resource: r, Reason: reason})
+			continue
+		}
+		result = append(result, item)
+	}
+	return result
+}
+
+// removeProject removes the containers, then the networks and the given volumes of a project
+func (c *Controller) removeProject(project models.Project, volumes []models.UnusedVolume) {
+	for _, container := range project.Containers {
+		if err := c.model.RemoveContainer(container.ID); err != nil {
+			// Networks and volumes cannot be removed while a container still uses them
+			c.view.ShowError(fmt.Errorf("error removing container %s of project %s: %v", container.ID[:12], project.Name, err))
+			return
+		}
+		c.view.ShowContainerRemoved(container.ID, container.Names)
+	}
+
+	for _, network := range project.Networks {
+		if err := c.model.RemoveNetwork(network.ID); err != nil {
+			c.view.ShowError(fmt.Errorf("error removing network %s of project %s: %v", network.Name, project.Name, err))
+			continue
+		}
+		c.view.ShowSuccess(fmt.Sprintf("Network deleted: %s", network.Name))
+	}
+
+	for _, name := range c.removeVolumes(volumes).VolumesDeleted {
+		c.view.ShowSuccess(fmt.Sprintf("Volume deleted: %s", name))
+	}
+
+	c.view.ShowSuccess(fmt.Sprintf("Project %s removed.", project.Name))
+}
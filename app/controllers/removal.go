@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// removeImages removes the selected images one by one, as a prune would remove more than the selection
+func (c *Controller) removeImages(images []image.Summary) image.PruneReport {
+	var report image.PruneReport
+	for _, img := range images {
+		deleted, err := c.model.RemoveImage(img.ID, append(append([]string{}, img.RepoTags...), img.RepoDigests...))
+		report.ImagesDeleted = append(report.ImagesDeleted, deleted...)
+		if err != nil {
+			c.view.ShowError(fmt.Errorf("error removing image %s: %v", models.ShortID(img.ID), err))
+			continue
+		}
+		report.SpaceReclaimed += uint64(max(img.Size, 0))
+	}
+	return report
+}
+
+// removeVolumes removes the selected volumes one by one
+// With a backup directory, each volume is archived first and kept when its backup fails
+func (c *Controller) removeVolumes(volumes []models.UnusedVolume) volume.PruneReport {
+	var report volume.PruneReport
+	for _, vol := range volumes {
+		if dir := GetConfig().BackupDir; dir != "" {
+			backup, err := c.model.BackupVolume(vol.Volume, dir)
+			if err != nil {
+				c.view.ShowError(fmt.Errorf("error backing up volume %s, it is kept: %v", vol.Name, err))
+				continue
+			}
+			c.view.ShowVolumeBackedUp(vol.Name, backup)
+		}
+		if err := c.model.RemoveVolume(vol.Name); err != nil {
+			c.view.ShowError(fmt.Errorf("error removing volume %s: %v", vol.Name, err))
+			continue
+		}
+		report.VolumesDeleted = append(report.VolumesDeleted, vol.Name)
+		if vol.UsageData != nil {
+			report.SpaceReclaimed += uint64(max(vol.UsageData.Size, 0))
+		}
+	}
+	return report
+}
+
+// removeNetworks removes the selected networks one by one
+func (c *Controller) removeNetworks(networks []network.Summary) network.PruneReport {
+	var report network.PruneReport
+	for _, net := range networks {
+		if err := c.model.RemoveNetwork(net.ID); err != nil {
+			c.view.ShowError(fmt.Errorf("error removing network %s: %v", net.Name, err))
+			continue
+		}
+		report.NetworksDeleted = append(report.NetworksDeleted, net.Name)
+	}
+	return report
+}
+
+// removeBuilds removes the selected build cache records
+func (c *Controller) removeBuilds(builds []types.BuildCache) {
+	if len(builds) == 0 {
+		c.view.ShowBuildsPruneResult(&types.BuildCachePruneReport{})
+		return
+	}
+
+	ids := make([]string, 0, len(builds))
+	for _, build := range builds {
+		ids = append(ids, build.ID)
+	}
+
+	report, err := c.model.RemoveBuilds(ids)
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error removing builds: %v", err))
+		return
+	}
+	c.view.ShowBuildsPruneResult(report)
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+)
+
+// RunRestoreVolume recreates a volume from a backup archive made with --backup-dir
+func (c *Controller) RunRestoreVolume(archive string) {
+	c.view.ShowTitle(fmt.Sprintf("Restoring volume from %s...", archive))
+
+	vol, err := c.model.RestoreVolume(archive, GetConfig().RestoreName)
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error restoring volume: %v", err))
+		return
+	}
+
+	c.view.ShowSuccess(fmt.Sprintf("Volume restored: %s", vol.Name))
+	if models.IsAnonymousVolume(vol) {
+		c.view.ShowAnonymousRestoreWarning(vol.Name)
+	}
+}
@@ -0,0 +1,506 @@
+package controllers
+
+import (
+	"docker-cleanup/app/expr"
+	"docker-cleanup/app/models"
+	"docker-cleanup/app/workspace"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// resourceFilter is a parsed rule filter such as "label=env=ci" or "name!=tmp-*"
+type resourceFilter struct {
+	key    string
+	name   string
+	value  string
+	negate bool
+}
+
+// parseFilter parses a filter of the form key=value or key!=value, where key is label or name
+func parseFilter(s string) (resourceFilter, error) {
+	var f resourceFilter
+
+	key, value, found := strings.Cut(s, "=")
+	if !found {
+		return f, fmt.Errorf("invalid filter %q: expected key=value", s)
+	}
+	if strings.HasSuffix(key, "!") {
+		key = strings.TrimSuffix(key, "!")
+		f.negate = true
+	}
+	f.key = key
+
+	switch key {
+	case "label":
+		f.name, f.value, _ = strings.Cut(value, "=")
+		if f.name == "" {
+			return f, fmt.Errorf("invalid filter %q: missing label name", s)
+		}
+	case "name":
+		if _, err := path.Match(value, ""); err != nil {
+			return f, fmt.Errorf("invalid filter %q: %v", s, err)
+		}
+		f.value = value
+	default:
+		return f, fmt.Errorf("invalid filter %q: unknown key %q", s, key)
+	}
+
+	return f, nil
+}
+
+// matches reports whether the resource satisfies the filter
+func (f resourceFilter) matches(r models.Resource) bool {
+	var match bool
+	switch f.key {
+	case "label":
+		value, ok := r.Labels[f.name]
+		match = ok && (f.value == "" || value == f.value)
+	case "name":
+		match, _ = path.Match(f.value, r.Name)
+	}
+	return match != f.negate
+}
+
+// resourceSchema declares the variables available to where expressions
+var resourceSchema = expr.Schema{
+	"kind":       expr.TypeString,
+	"name":       expr.TypeString,
+	"size":       expr.TypeSize,
+	"age":        expr.TypeDuration,
+	"labels":     expr.TypeMap,
+	"state":      expr.TypeString,
+	"exit_code":  expr.TypeInt,
+	"references": expr.TypeInt,
+	"idle":       expr.TypeDuration,
+	"stopped":    expr.TypeDuration,
+	"lifecycle":  expr.TypeString,
+}
+
+// compileWhere compiles a where expression against the resource variables
+func compileWhere(src string) (*expr.Program, error) {
+	return expr.Compile(src, resourceSchema)
+}
+
+// resourceVars returns the values of the where expression variables for a resource
+func resourceVars(r models.Resource) expr.Vars {
+	return expr.Vars{
+		"kind":       r.Kind,
+		"name":       r.Name,
+		"size":       expr.Size(r.Size),
+		"age":        r.Age(),
+		"labels":     r.Labels,
+		"state":      r.State,
+		"exit_code":  r.ExitCode,
+		"references": r.References,
+		"idle":       r.Idle(),
+		"stopped":    r.Stopped(),
+		"lifecycle":  r.Lifecycle,
+	}
+}
+
+// wherePrograms returns the compiled global and per-kind where expressions
+func (c *Controller) wherePrograms(kind string) ([]*expr.Program, error) {
+	var programs []*expr.Program
+	for _, src := range []string{GetConfig().Where, c.rule(kind).Where} {
+		if src == "" {
+			continue
+		}
+		if _, ok := c.programs[src]; !ok {
+			program, err := compileWhere(src)
+			if err != nil {
+				return nil, fmt.Errorf("where: %v", err)
+			}
+			c.programs[src] = program
+		}
+		programs = append(programs, c.programs[src])
+	}
+	return programs, nil
+}
+
+// rule returns the policy rule for a kind
+func (c *Controller) rule(kind string) Rule {
+	return GetConfig().EffectiveRules()[kind]
+}
+
+// enabled reports whether a kind takes part in the all command
+func (c *Controller) enabled(kind string) bool {
+	enabled := c.rule(kind).Enabled
+	return enabled == nil || *enabled
+}
+
+// optedIn reports whether an opt-in kind takes part in the all command
+// It must be requested with its flag or explicitly enabled by the policy
+func (c *Controller) optedIn(kind string) bool {
+	enabled := c.rule(kind).Enabled
+	return enabled != nil && *enabled
+}
+
+// olderThan returns the minimum age of the resources of a kind, from its rule, the global setting or its default
+// The kinds with a default age only take the global setting when it is given as a flag or environment variable,
+// even 0, as the one of the policy file is meant for the other kinds
+func (c *Controller) olderThan(kind string) time.Duration {
+	if age := c.rule(kind).OlderThan; age != nil {
+		return time.Duration(*age)
+	}
+	if age, ok := defaultOlderThan[kind]; ok && !GetConfig().OlderThanSet {
+		return age
+	}
+	return time.Duration(GetConfig().OlderThan)
+}
+
+// defaultOlderThan is the minimum age of the resources of the kinds that are hard to recreate, unless set for them
+// Services scaled to zero may be down for maintenance only, stopped builders hold build cache that is slow to rebuild
+// and stopped projects may only be paused between working sessions
+var defaultOlderThan = map[string]time.Duration{
+	KindServices: 7 * 24 * time.Hour,
+	KindBuilders: 7 * 24 * time.Hour,
+	KindProjects: 7 * 24 * time.Hour,
+}
+
+// unwrittenFor returns the minimum time since the content of the resources of a kind was last written
+func (c *Controller) unwrittenFor(kind string) time.Duration {
+	if idle := c.rule(kind).UnwrittenFor; idle != nil {
+		return time.Duration(*idle)
+	}
+	return 0
+}
+
+// readLastWrites reads the last write of the volumes when the listing, the unwritten filter or where expressions use it
+// Only to apply the unwritten filter, the walk of a volume stops at the first file written too recently for it
+func (c *Controller) readLastWrites(volumes []models.UnusedVolume, selecting bool) {
+	programs, _ := c.wherePrograms(KindVolumes)
+	idle := c.unwrittenFor(KindVolumes)
+	if !GetConfig().DryRun && (!selecting || idle == 0 && len(programs) == 0) {
+		return
+	}
+
+	var stopAfter time.Time
+	if selecting && idle > 0 && len(programs) == 0 {
+		stopAfter = time.Now().Add(-idle)
+	}
+	for i := range volumes {
+		volumes[i].ReadLastWrite(stopAfter)
+	}
+}
+
+// accepts reports whether the rule of a kind selects the resource
+func (c *Controller) accepts(kind string, r models.Resource) bool {
+	// Resources without a creation time, such as plugins, are not filtered by age
+	if age := c.olderThan(kind); age > 0 && !r.Created.IsZero() && r.Age() < age {
+		return false
+	}
+	// Resources whose last write is unknown are never taken for idle
+	if idle := c.unwrittenFor(kind); idle > 0 && (r.LastWrite.IsZero() || r.Idle() < idle) {
+		return false
+	}
+	return !c.excludedByRule(kind, r)
+}
+
+// excludedByRule reports whether the filters, keep patterns, size bounds or where expressions of a kind exclude the resource
+// Unlike the age of the resource, these are never overridden, for instance by storage budgets
+func (c *Controller) excludedByRule(kind string, r models.Resource) bool {
+	rule := c.rule(kind)
+
+	for _, s := range rule.Filters {
+		f, err := parseFilter(s)
+		if err != nil || !f.matches(r) {
+			return true
+		}
+	}
+	if c.keptByRule(kind, r) {
+		return true
+	}
+	if rule.MinSize != nil && r.Size < int64(*rule.MinSize) {
+		return true
+	}
+	if rule.MaxSize != nil && r.Size > int64(*rule.MaxSize) {
+		return true
+	}
+	programs, err := c.wherePrograms(kind)
+	if err != nil {
+		c.view.ShowError(err)
+		return true
+	}
+	for _, program := range programs {
+		match, err := program.Eval(resourceVars(r))
+		if err != nil {
+			c.view.ShowError(fmt.Errorf("where: %v", err))
+			return true
+		}
+		if !match {
+			return true
+		}
+	}
+	return false
+}
+
+// needsSize reports whether the rule of a kind compares resource sizes
+func (c *Controller) needsSize(kind string) bool {
+	rule := c.rule(kind)
+	if rule.MinSize != nil || rule.MaxSize != nil {
+		return true
+	}
+	// Invalid expressions select nothing, see accepts
+	programs, _ := c.wherePrograms(kind)
+	for _, program := range programs {
+		if program.Uses("size") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadServices collects the images and networks used by swarm services when the node is a swarm manager
+// Returns false when they cannot be retrieved, as resources used by services would then be taken for unused
+func (c *Controller) loadServices() bool {
+	if c.services != nil {
+		return true
+	}
+
+	manager, err := c.model.IsSwarmManager()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving swarm state: %v", err))
+		return false
+	}
+
+	c.manager = manager
+	services := &models.ServiceReferences{}
+	if manager {
+		if services, err = c.model.GetServiceReferences(); err != nil {
+			c.view.ShowError(fmt.Errorf("error retrieving swarm services: %v", err))
+			return false
+		}
+	}
+
+	// Image references are compared in the same short form as the tags of local images
+	images := make(map[string][]string, len(services.Images))
+	for ref, names := range services.Images {
+		images[workspace.NormalizeImage(ref)] = append(images[workspace.NormalizeImage(ref)], names...)
+	}
+	services.Images = images
+	c.services = services
+	return true
+}
+
+// serviceProtection returns why a resource is used by a swarm service, empty when it is not
+func (c *Controller) serviceProtection(r models.Resource) string {
+	if c.services == nil {
+		return ""
+	}
+
+	var services []string
+	switch r.Kind {
+	case "image":
+		for _, alias := range r.Aliases {
+			if services = c.services.Images[workspace.NormalizeImage(alias)]; services != nil {
+				break
+			}
+		}
+	case "network":
+		services = c.services.Networks[r.ID]
+		if services == nil {
+			services = c.services.Networks[r.Name]
+		}
+	}
+
+	switch len(services) {
+	case 0:
+		return ""
+	case 1:
+		return "used by service " + services[0]
+	default:
+		return "used by services " + strings.Join(services, ", ")
+	}
+}
+
+// protection returns why a resource is in use although no container uses it, empty when it is not
+func (c *Controller) protection(r models.Resource) string {
+	if reason := c.serviceProtection(r); reason != "" {
+		return reason
+	}
+	// Volume backups would pull the helper image again after each cleanup
+	if r.Kind == "image" && slices.ContainsFunc(r.Aliases, func(alias string) bool {
+		return workspace.NormalizeImage(alias) == workspace.NormalizeImage(models.BackupHelperImage)
+	}) {
+		return "helper image of volume backups"
+	}
+	if c.workspace == nil {
+		return ""
+	}
+
+	var file string
+	switch r.Kind {
+	case "image":
+		for _, alias := range r.Aliases {
+			if f, ok := c.workspace.Images[workspace.NormalizeImage(alias)]; ok {
+				file = f
+				break
+			}
+		}
+	case "volume":
+		file = c.workspace.Volumes[r.Name]
+	case "network":
+		file = c.workspace.Networks[r.Name]
+	}
+
+	if file == "" {
+		return ""
+	}
+	return "referenced by " + file
+}
+
+// selectResources keeps the items accepted by the rule of a kind
+// Items that are in use in a way the daemon does not know of are reported in dry-run mode
+// narrowed is true when some items were left out, in which case a daemon-side prune
+// would remove more than what was selected
+func selectResources[T any](c *Controller, kind string, items []T, resource func(T) models.Resource) (selected []T, narrowed bool) {
+	var kept []models.Kept
+	for _, item := range items {
+		r := resource(item)
+		if reason := c.protection(r); reason != "" {
+			kept = append(kept, models.Kept{Resource: r, Reason: reason})
+			continue
+		}
+		if c.accepts(kind, r) {
+			selected = append(selected, item)
+		}
+	}
+
+	if GetConfig().DryRun {
+		c.view.ShowKept(kept)
+	}
+	return selected, len(selected) != len(items)
+}
+
+// imageSelection returns how unused images are selected, the flags overriding the images rule
+func (c *Controller) imageSelection() models.ImageSelection {
+	rule := c.rule(KindImages)
+	selection := models.ImageSelection{
+		OlderThan:            c.olderThan(KindImages),
+		KeepLastOrder:        models.OrderTime,
+		KeepLastRepositories: rule.KeepLastRepositories,
+	}
+
+	if rule.KeepLast != nil {
+		selection.KeepLast = *rule.KeepLast
+	}
+	if rule.KeepLastOrder != "" {
+		selection.KeepLastOrder = rule.KeepLastOrder
+	}
+	return selection
+}
+
+// budgets returns the image storage budgets of the policy
+func (c *Controller) budgets() []models.Budget {
+	var budgets []models.Budget
+	for pattern, limit := range GetConfig().Budgets {
+		budgets = append(budgets, models.Budget{Pattern: pattern, Limit: int64(limit)})
+	}
+	return budgets
+}
+
+// keptByRule reports whether a keep pattern of the rule of a kind protects the resource
+func (c *Controller) keptByRule(kind string, r models.Resource) bool {
+	for _, pattern := range c.rule(kind).Keep {
+		if keep, _ := path.Match(pattern, r.Name); keep {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSelection returns how unused build cache records are selected, the flags overriding the builds rule
+func (c *Controller) buildSelection() models.BuildSelection {
+	rule := c.rule(KindBuilds)
+	selection := models.BuildSelection{
+		Types:  rule.CacheTypes,
+		Shared: rule.Shared,
+	}
+	if rule.UnusedFor != nil {
+		selection.UnusedFor = time.Duration(*rule.UnusedFor)
+	}
+	return selection
+}
+
+// keepStorage returns the number of bytes of the most recently used build cache to keep, the flag overriding the builds rule
+func (c *Controller) keepStorage() int64 {
+	if keep := c.rule(KindBuilds).KeepStorage; keep != nil {
+		return int64(*keep)
+	}
+	return 0
+}
+
+// includeNamedVolumes reports whether named volumes are selected, the flag overriding the volumes rule
+func (c *Controller) includeNamedVolumes() bool {
+	include := c.rule(KindVolumes).IncludeNamed
+	return include != nil && *include
+}
+
+// ignoreStoppedAttachments reports whether networks only stopped containers are attached to are selected,
+// the flag overriding the networks rule
+func (c *Controller) ignoreStoppedAttachments() bool {
+	ignore := c.rule(KindNetworks).IgnoreStoppedAttachments
+	return ignore != nil && *ignore
+}
+
+// stoppedAttachments describes the networks kept for the stopped containers attached to them
+func (c *Controller) stoppedAttachments(attachments []models.StoppedAttachment) []models.Kept {
+	kept := make([]models.Kept, 0, len(attachments))
+	for _, attachment := range attachments {
+		kept = append(kept, models.Kept{
+			Resource: models.NetworkResource(attachment.Summary),
+			Reason:   fmt.Sprintf("attached to stopped container(s) %s, use --ignore-stopped-attachments", strings.Join(attachment.Containers, ", ")),
+		})
+	}
+	return kept
+}
+
+// selectVolumes keeps the volumes stored on the host that hold no database, unless opted in
+// When names are given, only these volumes are kept and they may hold a database, as they were chosen one by one
+// The others are reported in dry-run mode, narrowed being true when there are some
+func (c *Controller) selectVolumes(volumes []models.UnusedVolume, names []string) (selected []models.UnusedVolume, narrowed bool) {
+	rule := c.rule(KindVolumes)
+	includeRemote := rule.IncludeRemote != nil && *rule.IncludeRemote
+	includeDatabases := rule.IncludeDatabases != nil && *rule.IncludeDatabases
+	drivers := rule.VolumeDrivers
+
+	for _, name := range names {
+		if !slices.ContainsFunc(volumes, func(vol models.UnusedVolume) bool { return vol.Name == name }) {
+			c.view.ShowError(fmt.Errorf("volume %s not found or in use", name))
+		}
+	}
+
+	var kept []models.Kept
+	for _, vol := range volumes {
+		named := slices.Contains(names, vol.Name)
+		if len(names) > 0 && !named {
+			narrowed = true
+			continue
+		}
+
+		var reason string
+		switch {
+		case vol.Remote != "" && !includeRemote && !(vol.Driver != models.LocalVolumeDriver && slices.Contains(drivers, vol.Driver)):
+			reason = fmt.Sprintf("remote data (%s), use --volume-driver or --include-remote-volumes", vol.Remote)
+		case vol.Database != "" && !includeDatabases && !named:
+			reason = fmt.Sprintf("likely %s database, use --include-databases or name the volume", vol.Database)
+		case named:
+			// Volumes chosen by name are protected like the others, but bypass the other rules
+			reason = c.protection(models.UnusedVolumeResource(vol))
+		}
+
+		if reason != "" {
+			kept = append(kept, models.Kept{Resource: models.UnusedVolumeResource(vol), Reason: reason})
+			continue
+		}
+		selected = append(selected, vol)
+	}
+
+	if GetConfig().DryRun {
+		c.view.ShowKept(kept)
+	}
+	return selected, narrowed || len(kept) > 0
+}
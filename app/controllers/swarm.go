@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"docker-cleanup/app/models"
+	"fmt"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// RunConfigCleanup executes the cleanup of swarm configs no service uses
+func (c *Controller) RunConfigCleanup() {
+	c.view.ShowTitle("Removing unused configs...")
+
+	if !c.loadServices() {
+		return
+	}
+	if !c.manager {
+		c.view.ShowSuccess("Not a swarm manager, no configs to remove.")
+		return
+	}
+
+	configs, err := c.model.GetUnusedConfigs()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving configs: %v", err))
+		return
+	}
+	configs, _ = selectResources(c, KindConfigs, configs, models.ConfigResource)
+
+	c.view.ShowConfigs(configs, GetConfig().DryRun)
+
+	if !GetConfig().DryRun && len(configs) > 0 {
+		var removed []swarm.Config
+		for _, config := range configs {
+			if err := c.model.RemoveConfig(config.ID); err != nil {
+				c.view.ShowError(fmt.Errorf("error removing config %s: %v", config.Spec.Name, err))
+				continue
+			}
+			removed = append(removed, config)
+		}
+		c.view.ShowConfigsRemoved(removed)
+	}
+}
+
+// RunSecretCleanup executes the cleanup of swarm secrets no service uses
+func (c *Controller) RunSecretCleanup() {
+	c.view.ShowTitle("Removing unused secrets...")
+
+	if !c.loadServices() {
+		return
+	}
+	if !c.manager {
+		c.view.ShowSuccess("Not a swarm manager, no secrets to remove.")
+		return
+	}
+
+	secrets, err := c.model.GetUnusedSecrets()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving secrets: %v", err))
+		return
+	}
+	secrets, _ = selectResources(c, KindSecrets, secrets, models.SecretResource)
+
+	c.view.ShowSecrets(secrets, GetConfig().DryRun)
+
+	if !GetConfig().DryRun && len(secrets) > 0 {
+		var removed []swarm.Secret
+		for _, secret := range secrets {
+			if err := c.model.RemoveSecret(secret.ID); err != nil {
+				c.view.ShowError(fmt.Errorf("error removing secret %s: %v", secret.Spec.Name, err))
+				continue
+			}
+			removed = append(removed, secret)
+		}
+		c.view.ShowSecretsRemoved(removed)
+	}
+}
+
+// RunServiceCleanup executes the cleanup of replicated services scaled to zero and not updated since --older-than
+// The resources they kept in use become candidates for the other cleanups once they are removed
+func (c *Controller) RunServiceCleanup() {
+	c.view.ShowTitle("Removing services scaled to zero...")
+
+	if !c.loadServices() {
+		return
+	}
+	if !c.manager {
+		c.view.ShowSuccess("Not a swarm manager, no services to remove.")
+		return
+	}
+
+	services, err := c.model.GetScaledDownServices()
+	if err != nil {
+		c.view.ShowError(fmt.Errorf("error retrieving services: %v", err))
+		return
+	}
+	services, _ = selectResources(c, KindServices, services, models.ServiceResource)
+
+	c.view.ShowScaledDownServices(services, GetConfig().DryRun)
+
+	if !GetConfig().DryRun && len(services) > 0 {
+		var removed []models.ScaledDownService
+		for _, service := range services {
+			if err := c.model.RemoveService(service.ID); err != nil {
+				c.view.ShowError(fmt.Errorf("error removing service %s: %v", service.Spec.Name, err))
+				continue
+			}
+			removed = append(removed, service)
+		}
+		c.view.ShowServicesRemoved(removed)
+
+		// The resources of the removed services are no longer in use
+		c.services = nil
+	}
+}
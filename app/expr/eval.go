@@ -0,0 +1,275 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Size is a size in bytes, to distinguish size variables from plain integers
+type Size int64
+
+// Vars holds the values of the variables, which may be bool, int, Size,
+// time.Duration, string or map[string]string according to the schema
+type Vars map[string]any
+
+// node is a typed node of the syntax tree
+type node interface {
+	typ() Type
+	column() int
+	eval(vars Vars) any
+}
+
+type literalNode struct {
+	value any
+	t     Type
+	col   int
+}
+
+func (n *literalNode) typ() Type       { return n.t }
+func (n *literalNode) column() int     { return n.col }
+func (n *literalNode) eval(_ Vars) any { return n.value }
+
+type variableNode struct {
+	name string
+	t    Type
+	col  int
+}
+
+func (n *variableNode) typ() Type   { return n.t }
+func (n *variableNode) column() int { return n.col }
+
+// eval normalizes the value of the variable: numbers become int64, missing values their zero value
+func (n *variableNode) eval(vars Vars) any {
+	switch v := vars[n.name].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case Size:
+		return int64(v)
+	case time.Duration:
+		return int64(v)
+	case nil:
+		switch n.t {
+		case TypeBool:
+			return false
+		case TypeString:
+			return ""
+		case TypeMap:
+			return map[string]string(nil)
+		default:
+			return int64(0)
+		}
+	default:
+		return v
+	}
+}
+
+type indexNode struct {
+	target node
+	key    node
+	col    int
+}
+
+func (n *indexNode) typ() Type   { return TypeString }
+func (n *indexNode) column() int { return n.col }
+
+func (n *indexNode) eval(vars Vars) any {
+	value, _ := n.lookup(vars)
+	return value
+}
+
+// lookup returns the map entry and whether it exists
+func (n *indexNode) lookup(vars Vars) (string, bool) {
+	m, _ := n.target.eval(vars).(map[string]string)
+	value, ok := m[n.key.eval(vars).(string)]
+	return value, ok
+}
+
+type hasNode struct {
+	index *indexNode
+	col   int
+}
+
+func (n *hasNode) typ() Type   { return TypeBool }
+func (n *hasNode) column() int { return n.col }
+
+func (n *hasNode) eval(vars Vars) any {
+	_, ok := n.index.lookup(vars)
+	return ok
+}
+
+type callNode struct {
+	name string
+	args []node
+	re   *regexp.Regexp
+	col  int
+}
+
+func (n *callNode) typ() Type   { return TypeBool }
+func (n *callNode) column() int { return n.col }
+
+func (n *callNode) eval(vars Vars) any {
+	s, arg := n.args[0].eval(vars).(string), n.args[1].eval(vars).(string)
+	switch n.name {
+	case "contains":
+		return strings.Contains(s, arg)
+	case "startsWith":
+		return strings.HasPrefix(s, arg)
+	case "endsWith":
+		return strings.HasSuffix(s, arg)
+	default:
+		re := n.re
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(arg); err != nil {
+				panic(evalError{n.args[1].column(), fmt.Sprintf("invalid regular expression: %v", err)})
+			}
+		}
+		return re.MatchString(s)
+	}
+}
+
+type notNode struct {
+	operand node
+	col     int
+}
+
+func (n *notNode) typ() Type          { return TypeBool }
+func (n *notNode) column() int        { return n.col }
+func (n *notNode) eval(vars Vars) any { return !n.operand.eval(vars).(bool) }
+
+type logicalNode struct {
+	op          string
+	left, right node
+	col         int
+}
+
+func (n *logicalNode) typ() Type   { return TypeBool }
+func (n *logicalNode) column() int { return n.left.column() }
+
+func (n *logicalNode) eval(vars Vars) any {
+	left := n.left.eval(vars).(bool)
+	if n.op == "&&" {
+		return left && n.right.eval(vars).(bool)
+	}
+	return left || n.right.eval(vars).(bool)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+	col         int
+}
+
+func (n *compareNode) typ() Type   { return TypeBool }
+func (n *compareNode) column() int { return n.left.column() }
+
+func (n *compareNode) eval(vars Vars) any {
+	left, right := n.left.eval(vars), n.right.eval(vars)
+
+	var cmp int
+	switch l := left.(type) {
+	case bool:
+		if n.op == "==" {
+			return l == right.(bool)
+		}
+		return l != right.(bool)
+	case int64:
+		cmp = compare(l, right.(int64))
+	case string:
+		cmp = strings.Compare(l, right.(string))
+	}
+
+	switch n.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func compare(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// evalError is raised during evaluation and turned into an Error by Eval
+type evalError struct {
+	column  int
+	message string
+}
+
+// Program is a compiled expression
+type Program struct {
+	src  string
+	root node
+	used map[string]bool
+}
+
+// Compile parses and type-checks an expression, which must evaluate to a bool
+func Compile(src string, schema Schema) (*Program, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, schema: schema, tokens: tokens, used: make(map[string]bool)}
+	if p.peek().kind == tokenEOF {
+		return nil, p.errorf(1, "empty expression")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t.column, "unexpected %s", describe(t))
+	}
+	if root.typ() != TypeBool {
+		return nil, p.errorf(root.column(), "expression must be a bool, found %s", root.typ())
+	}
+
+	return &Program{src: src, root: root, used: p.used}, nil
+}
+
+// String returns the source of the expression
+func (p *Program) String() string {
+	return p.src
+}
+
+// Uses reports whether the expression reads a variable
+func (p *Program) Uses(name string) bool {
+	return p.used[name]
+}
+
+// Eval evaluates the expression against the values of its variables
+func (p *Program) Eval(vars Vars) (result bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(evalError)
+			if !ok {
+				panic(r)
+			}
+			err = &Error{p.src, e.column, e.message}
+		}
+	}()
+
+	return p.root.eval(vars).(bool), nil
+}
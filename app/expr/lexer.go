@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenKind identifies the lexical class of a token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+// token is a lexical unit of an expression, with the column it starts at
+type token struct {
+	kind   tokenKind
+	text   string
+	column int
+}
+
+// operators lists the operators and punctuation, longest first
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+// Error is a parse, type or evaluation error located at a column of the expression
+type Error struct {
+	Source  string
+	Column  int
+	Message string
+}
+
+// Error returns the message followed by the expression with a marker under the offending column
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s\n  %s\n  %s^", e.Column, e.Message, e.Source, strings.Repeat(" ", max(e.Column-1, 0)))
+}
+
+// lex splits an expression into tokens
+func lex(src string) ([]token, error) {
+	var tokens []token
+
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		column := i + 1
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(src) && (src[i] == '_' || unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, src[start:i], column})
+
+		case unicode.IsDigit(c):
+			// Numbers may carry a size or duration unit, such as 1.5GB or 14d
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			for i < len(src) && unicode.IsLetter(rune(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, src[start:i], column})
+
+		case c == '"' || c == '\'':
+			var value strings.Builder
+			i++
+			closed := false
+			for i < len(src) {
+				if src[i] == '\\' && i+1 < len(src) {
+					value.WriteByte(src[i+1])
+					i += 2
+					continue
+				}
+				if rune(src[i]) == c {
+					closed = true
+					i++
+					break
+				}
+				value.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, &Error{src, column, "unterminated string"}
+			}
+			tokens = append(tokens, token{tokenString, value.String(), column})
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(src[i:], op) {
+					tokens = append(tokens, token{tokenOperator, op, column})
+					i += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &Error{src, column, fmt.Sprintf("unexpected character %q", c)}
+			}
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(src) + 1}), nil
+}
@@ -0,0 +1,323 @@
+package expr
+
+import (
+	"docker-cleanup/app/units"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Type is the static type of a value of the expression language
+type Type int
+
+const (
+	TypeBool Type = iota
+	TypeInt
+	TypeSize
+	TypeDuration
+	TypeString
+	TypeMap
+)
+
+// String returns the name of the type as shown in error messages
+func (t Type) String() string {
+	return [...]string{"bool", "int", "size", "duration", "string", "map"}[t]
+}
+
+// Schema declares the variables an expression can use and their types
+type Schema map[string]Type
+
+// durationSuffixes are the units that make a number literal a duration, the others make it a size
+var durationSuffixes = []string{"s", "m", "h", "d", "w"}
+
+// functions declares the number of arguments of the built-in string functions
+var functions = map[string]int{
+	"contains":   2,
+	"startsWith": 2,
+	"endsWith":   2,
+	"matches":    2,
+}
+
+// parser builds a typed syntax tree from the tokens of an expression
+type parser struct {
+	src    string
+	schema Schema
+	tokens []token
+	pos    int
+	used   map[string]bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(column int, format string, args ...any) error {
+	return &Error{p.src, column, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(op string) error {
+	t := p.next()
+	if t.kind != tokenOperator || t.text != op {
+		return p.errorf(t.column, "expected %q, found %s", op, describe(t))
+	}
+	return nil
+}
+
+// describe names a token in error messages
+func describe(t token) string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// parseOr parses a || b
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().text == "||" {
+		op := p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.logical(op, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// parseAnd parses a && b
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOperator && p.peek().text == "&&" {
+		op := p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.logical(op, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) logical(op token, left, right node) (node, error) {
+	for _, operand := range []node{left, right} {
+		if operand.typ() != TypeBool {
+			return nil, p.errorf(operand.column(), "operator %s expects bool operands, found %s", op.text, operand.typ())
+		}
+	}
+	return &logicalNode{op.text, left, right, op.column}, nil
+}
+
+// parseUnary parses !a
+func (p *parser) parseUnary() (node, error) {
+	if t := p.peek(); t.kind == tokenOperator && t.text == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != TypeBool {
+			return nil, p.errorf(operand.column(), "operator ! expects a bool operand, found %s", operand.typ())
+		}
+		return &notNode{operand, t.column}, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses a == b and the other comparison operators
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	lt, rt := left.typ(), right.typ()
+	numeric := func(t Type) bool { return t == TypeInt || t == TypeSize }
+	switch {
+	case lt == TypeMap || rt == TypeMap:
+		return nil, p.errorf(t.column, "maps cannot be compared, index them with [\"key\"]")
+	case lt != rt && !(numeric(lt) && numeric(rt)):
+		hint := ""
+		if lt == TypeDuration || rt == TypeDuration {
+			hint = ", write durations with a unit such as 14d"
+		}
+		return nil, p.errorf(right.column(), "cannot compare %s with %s%s", lt, rt, hint)
+	case lt == TypeBool && t.text != "==" && t.text != "!=":
+		return nil, p.errorf(t.column, "operator %s is not defined on bool", t.text)
+	}
+
+	return &compareNode{t.text, left, right, t.column}, nil
+}
+
+// parsePrimary parses literals, variables, indexing, calls and parentheses
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenNumber:
+		return p.number(t)
+
+	case tokenString:
+		return &literalNode{t.text, TypeString, t.column}, nil
+
+	case tokenIdent:
+		if t.text == "true" || t.text == "false" {
+			return &literalNode{t.text == "true", TypeBool, t.column}, nil
+		}
+		if next := p.peek(); next.kind == tokenOperator && next.text == "(" {
+			return p.call(t)
+		}
+
+		typ, ok := p.schema[t.text]
+		if !ok {
+			return nil, p.errorf(t.column, "unknown variable %q", t.text)
+		}
+		p.used[t.text] = true
+		var n node = &variableNode{t.text, typ, t.column}
+
+		if next := p.peek(); next.kind == tokenOperator && next.text == "[" {
+			if typ != TypeMap {
+				return nil, p.errorf(next.column, "%s of type %s cannot be indexed", t.text, typ)
+			}
+			p.next()
+			key, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if key.typ() != TypeString {
+				return nil, p.errorf(key.column(), "map index must be a string, found %s", key.typ())
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &indexNode{n, key, t.column}
+		}
+		return n, nil
+
+	case tokenOperator:
+		if t.text == "(" {
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+	}
+
+	return nil, p.errorf(t.column, "unexpected %s", describe(t))
+}
+
+// number parses an integer, size or duration literal
+func (p *parser) number(t token) (node, error) {
+	if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+		return &literalNode{n, TypeInt, t.column}, nil
+	}
+
+	for _, suffix := range durationSuffixes {
+		if strings.HasSuffix(t.text, suffix) {
+			d, err := units.ParseDuration(t.text)
+			if err != nil {
+				return nil, p.errorf(t.column, "invalid duration %q", t.text)
+			}
+			return &literalNode{int64(d), TypeDuration, t.column}, nil
+		}
+	}
+
+	size, err := units.ParseSize(t.text)
+	if err != nil {
+		return nil, p.errorf(t.column, "invalid number %q, expected an integer, a size such as 1GB or a duration such as 14d", t.text)
+	}
+	return &literalNode{size, TypeSize, t.column}, nil
+}
+
+// call parses a call to has() or one of the string functions
+func (p *parser) call(name token) (node, error) {
+	p.next() // (
+
+	var args []node
+	for p.peek().kind != tokenOperator || p.peek().text != ")" {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next() // )
+
+	if name.text == "has" {
+		if len(args) != 1 {
+			return nil, p.errorf(name.column, "has expects 1 argument, found %d", len(args))
+		}
+		index, ok := args[0].(*indexNode)
+		if !ok {
+			return nil, p.errorf(args[0].column(), "has expects a map entry such as labels[\"team\"]")
+		}
+		return &hasNode{index, name.column}, nil
+	}
+
+	arity, ok := functions[name.text]
+	if !ok {
+		return nil, p.errorf(name.column, "unknown function %q", name.text)
+	}
+	if len(args) != arity {
+		return nil, p.errorf(name.column, "%s expects %d arguments, found %d", name.text, arity, len(args))
+	}
+	for _, arg := range args {
+		if arg.typ() != TypeString {
+			return nil, p.errorf(arg.column(), "%s expects string arguments, found %s", name.text, arg.typ())
+		}
+	}
+
+	n := &callNode{name: name.text, args: args, col: name.column}
+	if pattern, ok := args[1].(*literalNode); ok && name.text == "matches" {
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, p.errorf(pattern.column(), "invalid regular expression: %v", err)
+		}
+		n.re = re
+	}
+	return n, nil
+}
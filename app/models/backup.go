@@ -0,0 +1,382 @@
+package models
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/klauspost/compress/zstd"
+)
+
+// BackupHelperImage is the image of the short-lived containers used to read and write volumes
+// when their mountpoint cannot be accessed directly
+const BackupHelperImage = "busybox:latest"
+
+// archiveRoot is the directory holding the content of the volume in backup archives,
+// which is also where helper containers mount the volume
+const archiveRoot = "volume"
+
+// backupTimeFormat is the format of the timestamp in the names of backup files
+const backupTimeFormat = "20060102-150405"
+
+// VolumeMetadata describes a backed up volume, so that it can be recreated as it was
+type VolumeMetadata struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+	CreatedAt  string            `json:"created_at,omitempty"`
+	BackedUpAt time.Time         `json:"backed_up_at"`
+}
+
+// VolumeBackup locates the files of a volume backup
+type VolumeBackup struct {
+	Archive  string
+	Metadata string
+}
+
+// MetadataFile returns the metadata file that goes with a backup archive
+func MetadataFile(archive string) string {
+	return strings.TrimSuffix(archive, ".tar.zst") + ".json"
+}
+
+// canAccess reports whether a volume mountpoint can be read and written directly,
+// which requires running as root on the host of the daemon
+func canAccess(mountpoint string) bool {
+	if mountpoint == "" || os.Geteuid() != 0 {
+		return false
+	}
+	_, err := os.Stat(mountpoint)
+	return err == nil
+}
+
+// BackupVolume archives the content of a volume to DIR/<volume>-<timestamp>.tar.zst, with its metadata in a JSON file
+// The archive is removed when the backup fails, so that an incomplete one is never mistaken for a good one
+func (d *DockerClient) BackupVolume(vol volume.Volume, dir string) (VolumeBackup, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return VolumeBackup{}, err
+	}
+
+	now := time.Now().UTC()
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s", vol.Name, now.Format(backupTimeFormat)))
+	backup := VolumeBackup{Archive: base + ".tar.zst", Metadata: base + ".json"}
+
+	if err := d.writeArchive(vol, backup.Archive); err != nil {
+		os.Remove(backup.Archive)
+		return VolumeBackup{}, err
+	}
+
+	metadata, err := json.MarshalIndent(VolumeMetadata{
+		Name:       vol.Name,
+		Driver:     vol.Driver,
+		Labels:     vol.Labels,
+		Options:    vol.Options,
+		CreatedAt:  vol.CreatedAt,
+		BackedUpAt: now,
+	}, "", "  ")
+	if err != nil {
+		os.Remove(backup.Archive)
+		return VolumeBackup{}, err
+	}
+	if err := os.WriteFile(backup.Metadata, append(metadata, '\n'), 0o600); err != nil {
+		os.Remove(backup.Archive)
+		return VolumeBackup{}, err
+	}
+
+	return backup, nil
+}
+
+// writeArchive writes the content of a volume to a zstd compressed tar archive
+func (d *DockerClient) writeArchive(vol volume.Volume, file string) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder, err := zstd.NewWriter(f)
+	if err != nil {
+		return err
+	}
+
+	if canAccess(vol.Mountpoint) {
+		err = tarDirectory(encoder, vol.Mountpoint)
+	} else {
+		err = d.withHelper(vol.Name, true, func(id string) error {
+			content, _, err := d.client.CopyFromContainer(d.ctx, id, "/"+archiveRoot)
+			if err != nil {
+				return err
+			}
+			defer content.Close()
+			_, err = io.Copy(encoder, content)
+			return err
+		})
+	}
+	if err != nil {
+		encoder.Close()
+		return err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// tarDirectory writes a directory as a tar stream, its content under archiveRoot
+func tarDirectory(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		switch {
+		case info.Mode()&fs.ModeSymlink != 0:
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular() && !info.IsDir():
+			// Sockets, pipes and devices cannot be restored meaningfully
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(archiveRoot, filepath.ToSlash(rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// RestoreVolume recreates a volume from a backup archive and its metadata file
+// The volume is named after the backed up one unless a name is given, and must not exist yet
+func (d *DockerClient) RestoreVolume(archive string, name string) (volume.Volume, error) {
+	data, err := os.ReadFile(MetadataFile(archive))
+	if err != nil {
+		return volume.Volume{}, err
+	}
+	var metadata VolumeMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return volume.Volume{}, fmt.Errorf("%s: %v", MetadataFile(archive), err)
+	}
+	if name == "" {
+		name = metadata.Name
+	}
+
+	if _, err := d.client.VolumeInspect(d.ctx, name); err == nil {
+		return volume.Volume{}, fmt.Errorf("volume %s already exists", name)
+	} else if !errdefs.IsNotFound(err) {
+		return volume.Volume{}, err
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return volume.Volume{}, err
+	}
+	defer f.Close()
+	decoder, err := zstd.NewReader(f)
+	if err != nil {
+		return volume.Volume{}, err
+	}
+	defer decoder.Close()
+
+	// A volume restored with the anonymous label would be removed again by the next cleanup
+	labels := maps.Clone(metadata.Labels)
+	delete(labels, AnonymousVolumeLabel)
+	vol, err := d.client.VolumeCreate(d.ctx, volume.CreateOptions{
+		Name:       name,
+		Driver:     metadata.Driver,
+		DriverOpts: metadata.Options,
+		Labels:     labels,
+	})
+	if err != nil {
+		return volume.Volume{}, err
+	}
+
+	if canAccess(vol.Mountpoint) {
+		err = untarDirectory(decoder, vol.Mountpoint)
+	} else {
+		err = d.withHelper(vol.Name, false, func(id string) error {
+			return d.client.CopyToContainer(d.ctx, id, "/", decoder, container.CopyToContainerOptions{})
+		})
+	}
+	if err != nil {
+		// A partially restored volume is removed, so that the restore can be tried again
+		d.client.VolumeRemove(d.ctx, vol.Name, false)
+		return volume.Volume{}, err
+	}
+	return vol, nil
+}
+
+// untarDirectory extracts the content of a tar stream under archiveRoot to a directory
+// Entries that would be written outside of the directory are rejected, including through a symlink extracted before
+func untarDirectory(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rel, ok := strings.CutPrefix(path.Clean(header.Name), archiveRoot)
+		if !ok || rel != "" && !strings.HasPrefix(rel, "/") {
+			return fmt.Errorf("unexpected archive entry %s", header.Name)
+		}
+		rel = strings.TrimPrefix(rel, "/")
+		if rel == "" {
+			continue
+		}
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("unsafe archive entry %s", header.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		// A symlink entry is created in place of its target, any other entry is written through it
+		through := rel
+		if header.Typeflag == tar.TypeSymlink {
+			through = path.Dir(rel)
+		}
+		if err := checkNoSymlink(dir, through); err != nil {
+			return fmt.Errorf("unsafe archive entry %s: %v", header.Name, err)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, header.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		default:
+			continue
+		}
+
+		if err := os.Lchown(target, header.Uid, header.Gid); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeSymlink {
+			os.Chtimes(target, header.ModTime, header.ModTime)
+		}
+	}
+}
+
+// checkNoSymlink returns an error when a path under dir, or one of its parents, is an existing symlink
+func checkNoSymlink(dir, rel string) error {
+	current := dir
+	for _, part := range strings.Split(rel, "/") {
+		if part == "." {
+			continue
+		}
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", current)
+		}
+	}
+	return nil
+}
+
+// withHelper runs a function with a created, never started, helper container mounting the volume under archiveRoot
+// The daemon can copy files from and to the volumes of a container that is not running
+func (d *DockerClient) withHelper(volumeName string, readOnly bool, run func(id string) error) error {
+	if _, err := d.client.ImageInspect(d.ctx, BackupHelperImage); err != nil {
+		if !errdefs.IsNotFound(err) {
+			return err
+		}
+		progress, err := d.client.ImagePull(d.ctx, BackupHelperImage, image.PullOptions{})
+		if err != nil {
+			return fmt.Errorf("error pulling %s: %v", BackupHelperImage, err)
+		}
+		_, err = io.Copy(io.Discard, progress)
+		progress.Close()
+		if err != nil {
+			return fmt.Errorf("error pulling %s: %v", BackupHelperImage, err)
+		}
+	}
+
+	helper, err := d.client.ContainerCreate(d.ctx, &container.Config{
+		Image: BackupHelperImage,
+		Cmd:   []string{"true"},
+	}, &container.HostConfig{
+		Mounts: []mount.Mount{{
+			Type:     mount.TypeVolume,
+			Source:   volumeName,
+			Target:   "/" + archiveRoot,
+			ReadOnly: readOnly,
+		}},
+	}, nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer d.client.ContainerRemove(d.ctx, helper.ID, container.RemoveOptions{Force: true})
+
+	return run(helper.ID)
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"cmp"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+)
+
+// Budget limits the storage used by the images of the repositories matching a pattern
+type Budget struct {
+	Pattern string
+	Limit   int64
+}
+
+// BudgetGroup reports the storage used by the images of a budget and the images selected to meet it
+type BudgetGroup struct {
+	Budget
+	// Images are all the images of the group
+	Images []image.Summary
+	// Usage is the estimated storage used by the group, see groupUsage
+	Usage int64
+	// Selected are the unused images selected to bring the group back under its limit, oldest first
+	Selected []image.Summary
+	// Projected is the estimated storage the group will use once the selected images are removed
+	Projected int64
+}
+
+// groupUsage estimates the storage used by a group of images
+// Exclusive bytes are summed and the largest shared size is counted once
+// This is an estimate, as the daemon reports the bytes an image shares with any image on the host, not within the group
+func groupUsage(images []image.Summary) int64 {
+	var exclusive, shared int64
+	for _, img := range images {
+		sharedSize := max(img.SharedSize, 0)
+		exclusive += img.Size - sharedSize
+		shared = max(shared, sharedSize)
+	}
+	return exclusive + shared
+}
+
+// GetOverBudgetImages groups images by budget and selects the oldest unused ones of each group over its limit
+// selected holds the IDs of images already selected for removal, which count as removed
+// keep reports images that must never be selected
+// An image belongs to the most specific budget matching the repository of one of its tags, see sortBudgets
+func (d *DockerClient) GetOverBudgetImages(budgets []Budget, selected map[string]bool, keep func(image.Summary) bool) ([]BudgetGroup, error) {
+	usage, err := d.diskUsage(types.ImageObject)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	usedImages := make(map[string]bool)
+	for _, container := range containers {
+		usedImages[container.ImageID] = true
+	}
+
+	budgets = sortBudgets(budgets)
+	groups := make([]BudgetGroup, len(budgets))
+	for i, budget := range budgets {
+		groups[i].Budget = budget
+	}
+
+	for _, img := range usage.Images {
+		for i := range groups {
+			if imageMatches(*img, groups[i].Pattern) {
+				groups[i].Images = append(groups[i].Images, *img)
+				break
+			}
+		}
+	}
+
+	for i := range groups {
+		group := &groups[i]
+		group.Usage = groupUsage(group.Images)
+
+		var remaining, candidates []image.Summary
+		for _, img := range group.Images {
+			if selected[img.ID] {
+				continue
+			}
+			remaining = append(remaining, img)
+			if !usedImages[img.ID] && !keep(img) {
+				candidates = append(candidates, img)
+			}
+		}
+
+		sort.Slice(candidates, func(a, b int) bool { return candidates[a].Created < candidates[b].Created })
+		for _, candidate := range candidates {
+			if groupUsage(remaining) <= group.Limit {
+				break
+			}
+			group.Selected = append(group.Selected, candidate)
+			remaining = removeImage(remaining, candidate.ID)
+		}
+		group.Projected = groupUsage(remaining)
+	}
+
+	return groups, nil
+}
+
+// sortBudgets returns a copy of the budgets, most specific first: longest literal prefix, then fewest wildcards,
+// so that registry.internal/app/* comes before registry.internal/*
+func sortBudgets(budgets []Budget) []Budget {
+	sorted := slices.Clone(budgets)
+	slices.SortFunc(sorted, func(a, b Budget) int {
+		if c := cmp.Compare(literalPrefix(b.Pattern), literalPrefix(a.Pattern)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(wildcards(a.Pattern), wildcards(b.Pattern)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Pattern, b.Pattern)
+	})
+	return sorted
+}
+
+// literalPrefix returns the length of a pattern before its first wildcard
+func literalPrefix(pattern string) int {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return i
+	}
+	return len(pattern)
+}
+
+// wildcards returns the number of wildcards of a pattern
+func wildcards(pattern string) int {
+	return strings.Count(pattern, "*") + strings.Count(pattern, "?") + strings.Count(pattern, "[")
+}
+
+// imageMatches reports whether the repository of one of the tags of an image matches a pattern
+func imageMatches(img image.Summary, pattern string) bool {
+	for _, ref := range img.RepoTags {
+		repository, _ := SplitTag(ref)
+		if match, _ := path.Match(pattern, repository); match {
+			return true
+		}
+	}
+	return false
+}
+
+// removeImage returns the images without the one with the given ID
+func removeImage(images []image.Summary, id string) []image.Summary {
+	var result []image.Summary
+	for _, img := range images {
+		if img.ID != id {
+			result = append(result, img)
+		}
+	}
+	return result
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Names of the containers and volumes buildx creates for the nodes of docker-container builders
+const (
+	BuilderPrefix      = "buildx_buildkit_"
+	builderStateSuffix = "_state"
+)
+
+// Builder is a node of a buildx docker-container builder, made of a BuildKit container and its state volume
+type Builder struct {
+	// Name is the name of the builder node, such as mybuilder0
+	Name string
+	// Container is the BuildKit container, nil when only the state volume is left
+	Container *types.Container
+	// Volume is the name of the state volume holding the build cache, empty when there is none
+	Volume string
+	// Size is the size of the writable layer of the container and of the state volume
+	Size    int64
+	Running bool
+	// LastActivity is the most recent creation, start or stop of the container
+	LastActivity time.Time
+}
+
+// builderNode returns the builder node of a container or volume name, empty when it is not one of a builder
+func builderNode(name string, suffix string) string {
+	name = strings.TrimPrefix(name, "/")
+	if !strings.HasPrefix(name, BuilderPrefix) || !strings.HasSuffix(name, suffix) {
+		return ""
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(name, BuilderPrefix), suffix)
+}
+
+// GetBuilders returns the buildx builder nodes found from their containers and state volumes, sorted by name
+func (d *DockerClient) GetBuilders() ([]Builder, error) {
+	builders := make(map[string]*Builder)
+	builder := func(name string) *Builder {
+		if builders[name] == nil {
+			builders[name] = &Builder{Name: name}
+		}
+		return builders[name]
+	}
+
+	args := filters.NewArgs()
+	args.Add("name", BuilderPrefix)
+	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{All: true, Size: true, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		if len(c.Names) == 0 {
+			continue
+		}
+		name := builderNode(c.Names[0], "")
+		if name == "" || strings.HasSuffix(name, builderStateSuffix) {
+			continue
+		}
+
+		b := builder(name)
+		b.Container = &c
+		b.Size += max(c.SizeRw, 0)
+		b.Running = isActive(c)
+
+		b.LastActivity = time.Unix(c.Created, 0)
+		if info, err := d.client.ContainerInspect(d.ctx, c.ID); err == nil && info.State != nil {
+			for _, t := range []time.Time{parseTime(info.State.StartedAt), parseTime(info.State.FinishedAt)} {
+				if t.After(b.LastActivity) {
+					b.LastActivity = t
+				}
+			}
+		}
+		if b.Running {
+			b.LastActivity = time.Now()
+		}
+	}
+
+	usage, err := d.diskUsage(types.VolumeObject)
+	if err != nil {
+		return nil, err
+	}
+	for _, vol := range usage.Volumes {
+		name := builderNode(vol.Name, builderStateSuffix)
+		if name == "" {
+			continue
+		}
+
+		b := builder(name)
+		b.Volume = vol.Name
+		if vol.UsageData != nil {
+			b.Size += max(vol.UsageData.Size, 0)
+		}
+		// The last use of a state volume left without its container is unknown, its creation is used instead
+		if b.Container == nil {
+			if created := parseTime(vol.CreatedAt); created.After(b.LastActivity) {
+				b.LastActivity = created
+			}
+		}
+	}
+
+	result := make([]Builder, 0, len(builders))
+	for _, b := range builders {
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
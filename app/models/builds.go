@@ -0,0 +1,180 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// CacheTypes are the types of build cache records
+var CacheTypes = []string{"regular", "source.local", "source.git.checkout", "exec.cachemount", "frontend", "internal"}
+
+// BuildSelection describes which unused build cache records are selected
+type BuildSelection struct {
+	// UnusedFor is the minimum time since the records were last used
+	UnusedFor time.Duration
+	// Types restricts the selection to these record types, when not empty
+	Types []string
+	// Shared selects only shared records when true and only exclusive ones when false, both when nil
+	Shared *bool
+}
+
+// LastUsed returns when a build cache record was last used, its creation for records never used
+func LastUsed(build types.BuildCache) time.Time {
+	if build.LastUsedAt == nil || build.UsageCount == 0 {
+		return build.CreatedAt
+	}
+	return *build.LastUsedAt
+}
+
+// matches reports whether a build cache record is selected
+func (s BuildSelection) matches(build types.BuildCache) bool {
+	if s.UnusedFor > 0 && time.Since(LastUsed(build)) < s.UnusedFor {
+		return false
+	}
+	if len(s.Types) > 0 {
+		found := false
+		for _, t := range s.Types {
+			found = found || build.Type == t
+		}
+		if !found {
+			return false
+		}
+	}
+	return s.Shared == nil || build.Shared == *s.Shared
+}
+
+// Prunable reports whether the daemon can apply the selection as prune filters
+// The daemon accepts a single value per filter, so selecting several types requires removing records one by one
+func (s BuildSelection) Prunable() bool {
+	return len(s.Types) <= 1
+}
+
+// filters returns the prune filters applying the selection
+func (s BuildSelection) filters() filters.Args {
+	pruneFilters := filters.NewArgs()
+	if s.UnusedFor > 0 {
+		pruneFilters.Add("unused-for", s.UnusedFor.String())
+	}
+	if len(s.Types) == 1 {
+		pruneFilters.Add("type", s.Types[0])
+	}
+	if s.Shared != nil {
+		pruneFilters.Add("shared", strconv.FormatBool(*s.Shared))
+	}
+	return pruneFilters
+}
+
+// KeepBuildStorage returns the build cache records left once the most recently used ones, up to limit bytes, are kept
+// Records are kept from the most recently and most often used, the order in which BuildKit would prune them in reverse
+func KeepBuildStorage(builds []types.BuildCache, limit int64) []types.BuildCache {
+	sorted := append([]types.BuildCache{}, builds...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := LastUsed(sorted[i]), LastUsed(sorted[j])
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return sorted[i].UsageCount > sorted[j].UsageCount
+	})
+
+	var kept int64
+	var full bool
+	var selected []types.BuildCache
+	for _, build := range sorted {
+		// Once a record does not fit, every less recently used one is removed
+		if !full && kept+build.Size <= limit {
+			kept += build.Size
+			continue
+		}
+		full = true
+		selected = append(selected, build)
+	}
+	return selected
+}
+
+// BuildGroup sums up the build cache records of one type and step
+type BuildGroup struct {
+	Type string
+	// Step is the build step the records were created by, parsed from their description
+	Step       string
+	Records    int
+	Size       int64
+	UsageCount int
+	LastUsed   time.Time
+	// Shared is the size of the records shared with other records, the rest being exclusive to the group
+	Shared int64
+}
+
+// stepPatterns turn the descriptions BuildKit gives to cache records into the build step that created them
+var stepPatterns = []struct {
+	pattern *regexp.Regexp
+	step    string
+}{
+	{regexp.MustCompile(`^\[[^\]]*\]\s+(.*)$`), "$1"},
+	{regexp.MustCompile(`^mount / from exec (?:/bin/sh -c )?(.*)$`), "RUN $1"},
+	{regexp.MustCompile(`^(?:cached )?mount (\S+) from exec .*$`), "cache mount $1"},
+	{regexp.MustCompile(`^local source for (.*)$`), "local source for $1"},
+	{regexp.MustCompile(`^pulled from ([^@]*)(?:@.*)?$`), "pulled from $1"},
+	{regexp.MustCompile(`^git snapshot for ([^#]*)(?:#.*)?$`), "git snapshot for $1"},
+}
+
+// maxStepLength is the length past which steps are truncated, so that long commands still group together
+const maxStepLength = 80
+
+// BuildStep returns the build step described by the description of a build cache record
+func BuildStep(description string) string {
+	step := strings.TrimSpace(description)
+	for _, p := range stepPatterns {
+		if p.pattern.MatchString(step) {
+			step = p.pattern.ReplaceAllString(step, p.step)
+			break
+		}
+	}
+	if step == "" {
+		return "(no description)"
+	}
+	if runes := []rune(step); len(runes) > maxStepLength {
+		step = string(runes[:maxStepLength-3]) + "..."
+	}
+	return step
+}
+
+// GroupBuilds groups build cache records by type and step, largest groups first
+func GroupBuilds(builds []types.BuildCache) []BuildGroup {
+	groups := make(map[[2]string]*BuildGroup)
+	for _, build := range builds {
+		key := [2]string{build.Type, BuildStep(build.Description)}
+		group := groups[key]
+		if group == nil {
+			group = &BuildGroup{Type: key[0], Step: key[1]}
+			groups[key] = group
+		}
+
+		group.Records++
+		group.Size += build.Size
+		group.UsageCount += build.UsageCount
+		if lastUsed := LastUsed(build); lastUsed.After(group.LastUsed) {
+			group.LastUsed = lastUsed
+		}
+		if build.Shared {
+			group.Shared += build.Size
+		}
+	}
+
+	result := make([]BuildGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Size != result[j].Size {
+			return result[i].Size > result[j].Size
+		}
+		return result[i].Type+result[i].Step < result[j].Type+result[j].Step
+	})
+	return result
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// Lifecycle classes of stopped containers, each with its own retention
+const (
+	// LifecycleDead containers failed to be removed or stopped
+	LifecycleDead = "dead"
+	// LifecycleNeverStarted containers were created but never started, usually by a failed docker run
+	LifecycleNeverStarted = "never-started"
+	// LifecycleRestartPolicy containers restart with the daemon, so they were likely stopped by hand and meant to come back
+	LifecycleRestartPolicy = "restart-policy"
+	// LifecycleExited containers ran and exited
+	LifecycleExited = "exited"
+)
+
+// isActive reports whether a container is running, paused or restarting, all of which keep its resources in use
+func isActive(c container.Summary) bool {
+	return c.State == "running" || c.State == "paused" || c.State == "restarting"
+}
+
+// LifecycleClasses lists the lifecycle classes of stopped containers
+var LifecycleClasses = []string{LifecycleDead, LifecycleNeverStarted, LifecycleRestartPolicy, LifecycleExited}
+
+// StoppedContainer is a stopped container with the details of its last run, which only inspect returns
+type StoppedContainer struct {
+	types.Container
+	// ExitCode is the exit code of the last run, 0 when the container never ran
+	ExitCode int
+	// FinishedAt is when the last run ended, zero when the container never ran
+	FinishedAt time.Time
+	// StartedAt is when the last run started, zero when the container was never started
+	StartedAt     time.Time
+	RestartPolicy container.RestartPolicyMode
+}
+
+// Lifecycle returns the lifecycle class of the container
+func (c StoppedContainer) Lifecycle() string {
+	switch {
+	case c.State == "dead":
+		return LifecycleDead
+	case c.StartedAt.IsZero():
+		return LifecycleNeverStarted
+	case c.RestartPolicy == container.RestartPolicyAlways || c.RestartPolicy == container.RestartPolicyUnlessStopped:
+		return LifecycleRestartPolicy
+	default:
+		return LifecycleExited
+	}
+}
+
+// StoppedSince returns when the container stopped, or was created when it never ran
+func (c StoppedContainer) StoppedSince() time.Time {
+	if c.Exited() {
+		return c.FinishedAt
+	}
+	return time.Unix(c.Created, 0)
+}
+
+// Exited reports whether the container ran and exited, so that its exit code is meaningful
+func (c StoppedContainer) Exited() bool {
+	return !c.FinishedAt.IsZero()
+}
+
+// Succeeded reports whether the last run of the container exited with code 0
+func (c StoppedContainer) Succeeded() bool {
+	return c.Exited() && c.ExitCode == 0
+}
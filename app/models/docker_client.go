@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -9,8 +10,10 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 )
 
 type DockerClient struct {
@@ -48,15 +51,46 @@ func (d *DockerClient) GetDiskUsage() (*types.DiskUsage, error) {
 	return &usage, nil
 }
 
-// GetStoppedContainers returns a list of stopped containers
+// diskUsage returns the disk usage report of some kinds of objects
+// It is the only report that includes the size of volumes and the shared size of images, which the daemon computes
+// for every object of the kind, hence the restriction to the kinds needed
+func (d *DockerClient) diskUsage(objects ...types.DiskUsageObject) (types.DiskUsage, error) {
+	return d.client.DiskUsage(d.ctx, types.DiskUsageOptions{Types: objects})
+}
+
+// GetStoppedContainers returns a list of stopped containers, with their last run and restart policy
+// withSize also computes the size of their writable layer, which is slower
 // Returns an error if the list cannot be retrieved
-func (d *DockerClient) GetStoppedContainers() ([]types.Container, error) {
+func (d *DockerClient) GetStoppedContainers(withSize bool) ([]StoppedContainer, error) {
 	args := filters.NewArgs()
 	args.Add("status", "exited")
 	args.Add("status", "created")
 	args.Add("status", "dead")
 
-	return d.client.ContainerList(d.ctx, container.ListOptions{All: true, Filters: args})
+	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{All: true, Size: withSize, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	stopped := make([]StoppedContainer, 0, len(containers))
+	for _, c := range containers {
+		info, err := d.client.ContainerInspect(d.ctx, c.ID)
+		if err != nil {
+			// The container was removed meanwhile
+			continue
+		}
+		s := StoppedContainer{Container: c}
+		if info.State != nil {
+			s.ExitCode = info.State.ExitCode
+			s.FinishedAt = parseTime(info.State.FinishedAt)
+			s.StartedAt = parseTime(info.State.StartedAt)
+		}
+		if info.HostConfig != nil {
+			s.RestartPolicy = info.HostConfig.RestartPolicy.Name
+		}
+		stopped = append(stopped, s)
+	}
+	return stopped, nil
 }
 
 // RemoveContainer removes a container
@@ -68,11 +102,25 @@ func (d *DockerClient) RemoveContainer(containerID string) error {
 	})
 }
 
-// GetUnusedImages returns a list of unused images
+// ImageSelection describes which unused images are selected
+type ImageSelection struct {
+	// OlderThan is the minimum age of the selected images
+	OlderThan time.Duration
+	// KeepLast keeps the images of the N most recent tags of each repository, when positive
+	KeepLast int
+	// KeepLastOrder ranks tags by creation time (OrderTime) or semantic version (OrderSemver)
+	KeepLastOrder string
+	// KeepLastRepositories restricts keep-last retention to the repositories matching these patterns
+	KeepLastRepositories []string
+}
+
+// GetUnusedImages returns a list of unused dangling images, which are the images PruneImages removes
+// Tagged images are only listed with keep-last retention, which selects the tags older than the kept ones
+// Intermediate images are left out, as they are removed with the images built on them
 // Returns an error if the list cannot be retrieved
-func (d *DockerClient) GetUnusedImages(olderThan int) ([]image.Summary, error) {
-	// Get all images
-	allImages, err := d.client.ImageList(d.ctx, image.ListOptions{All: true})
+func (d *DockerClient) GetUnusedImages(selection ImageSelection) ([]image.Summary, error) {
+	// Get the top-level images
+	allImages, err := d.client.ImageList(d.ctx, image.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -89,14 +137,23 @@ func (d *DockerClient) GetUnusedImages(olderThan int) ([]image.Summary, error) {
 		usedImages[container.ImageID] = true
 	}
 
+	// Map images holding one of the most recent tags of their repository
+	keptImages := make(map[string]bool)
+	if selection.KeepLast > 0 {
+		keptImages = keepLastImages(allImages, selection.KeepLast, selection.KeepLastOrder, selection.KeepLastRepositories)
+	}
+
 	// Filter unused images
 	var unusedImages []image.Summary
 	for _, image := range allImages {
-		if !usedImages[image.ID] {
+		if !IsDanglingImage(image) && selection.KeepLast <= 0 {
+			continue
+		}
+		if !usedImages[image.ID] && !keptImages[image.ID] {
 			// If olderThan is specified, check image age
-			if olderThan > 0 {
+			if selection.OlderThan > 0 {
 				imageAge := time.Since(time.Unix(image.Created, 0))
-				if imageAge.Hours() < float64(olderThan*24) {
+				if imageAge < selection.OlderThan {
 					continue
 				}
 			}
@@ -107,16 +164,55 @@ func (d *DockerClient) GetUnusedImages(olderThan int) ([]image.Summary, error) {
 	return unusedImages, nil
 }
 
-// PruneImages removes unused images
-func (d *DockerClient) PruneImages(olderThan int) (image.PruneReport, error) {
+// IsDanglingImage reports whether an image has no tag
+func IsDanglingImage(img image.Summary) bool {
+	for _, ref := range img.RepoTags {
+		if ref != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+// PruneImages removes unused dangling images, tagged images being kept without the dangling=false filter
+func (d *DockerClient) PruneImages(olderThan time.Duration) (image.PruneReport, error) {
 	pruneFilters := filters.NewArgs()
 	if olderThan > 0 {
-		timestamp := time.Now().Add(-time.Hour * 24 * time.Duration(olderThan)).Format(time.RFC3339)
+		timestamp := time.Now().Add(-olderThan).Format(time.RFC3339)
 		pruneFilters.Add("until", timestamp)
 	}
 	return d.client.ImagesPrune(d.ctx, pruneFilters)
 }
 
+// RemoveImage removes a single image and its untagged parents
+// Its references are removed first, as the daemon refuses to remove by ID an image referenced in several repositories
+// Returns the untagged and deleted references
+func (d *DockerClient) RemoveImage(imageID string, refs []string) ([]image.DeleteResponse, error) {
+	options := image.RemoveOptions{
+		Force:         false,
+		PruneChildren: true,
+	}
+
+	var deleted []image.DeleteResponse
+	for _, ref := range refs {
+		if strings.Contains(ref, "<none>") {
+			continue
+		}
+		response, err := d.client.ImageRemove(d.ctx, ref, options)
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, response...)
+	}
+
+	response, err := d.client.ImageRemove(d.ctx, imageID, options)
+	if errdefs.IsNotFound(err) && len(deleted) > 0 {
+		// Removing its last reference deleted the image
+		return deleted, nil
+	}
+	return append(deleted, response...), err
+}
+
 // GetDanglingImages gets the list of dangling images
 func (d *DockerClient) GetDanglingImages() ([]image.Summary, error) {
 	args := filters.NewArgs()
@@ -132,8 +228,9 @@ func (d *DockerClient) PruneDanglingImages() (image.PruneReport, error) {
 	return d.client.ImagesPrune(d.ctx, pruneFilters)
 }
 
-// GetUnusedVolumes gets the list of unused volumes
-func (d *DockerClient) GetUnusedVolumes() ([]volume.Volume, error) {
+// GetUnusedVolumes gets the list of unused volumes, with their size, see DescribeVolume for the rest
+// Named volumes are only listed when includeNamed is set, see IsAnonymousVolume
+func (d *DockerClient) GetUnusedVolumes(includeNamed bool) ([]UnusedVolume, error) {
 	// Get all volumes
 	volumes, err := d.client.VolumeList(d.ctx, volume.ListOptions{})
 	if err != nil {
@@ -161,65 +258,146 @@ func (d *DockerClient) GetUnusedVolumes() ([]volume.Volume, error) {
 		}
 	}
 
+	usage, err := d.diskUsage(types.VolumeObject)
+	if err != nil {
+		return nil, err
+	}
+	usageData := make(map[string]*volume.UsageData)
+	for _, vol := range usage.Volumes {
+		usageData[vol.Name] = vol.UsageData
+	}
+
 	// Filter unused volumes
-	var unusedVolumes []volume.Volume
+	var unusedVolumes []UnusedVolume
 	for _, volume := range volumes.Volumes {
-		if !usedVolumes[volume.Name] {
-			unusedVolumes = append(unusedVolumes, *volume)
+		if !usedVolumes[volume.Name] && (includeNamed || IsAnonymousVolume(*volume)) {
+			unused := DescribeVolume(*volume)
+			if unused.UsageData == nil {
+				unused.UsageData = usageData[volume.Name]
+			}
+			unusedVolumes = append(unusedVolumes, unused)
 		}
 	}
 
 	return unusedVolumes, nil
 }
 
-// PruneVolumes removes unused volumes
-func (d *DockerClient) PruneVolumes() (volume.PruneReport, error) {
+// RemoveVolume removes a single volume
+func (d *DockerClient) RemoveVolume(name string) error {
+	return d.client.VolumeRemove(d.ctx, name, false)
+}
+
+// PruneVolumes removes unused anonymous volumes, and unused named volumes when includeNamed is set
+// Daemons older than API 1.42 prune named volumes too, see PrunesAnonymousVolumesOnly
+func (d *DockerClient) PruneVolumes(includeNamed bool) (volume.PruneReport, error) {
 	pruneFilters := filters.NewArgs()
+	if includeNamed && d.PrunesAnonymousVolumesOnly() {
+		pruneFilters.Add("all", "true")
+	}
 	return d.client.VolumesPrune(d.ctx, pruneFilters)
 }
 
-// GetUnusedNetworks gets the list of unused networks
-func (d *DockerClient) GetUnusedNetworks() ([]network.Summary, error) {
+// PrunesAnonymousVolumesOnly reports whether a volume prune without the all filter leaves named volumes alone,
+// which is the case from API 1.42 on
+func (d *DockerClient) PrunesAnonymousVolumesOnly() bool {
+	d.client.NegotiateAPIVersion(d.ctx)
+	return versions.GreaterThanOrEqualTo(d.client.ClientVersion(), "1.42")
+}
+
+// StoppedAttachment is a network only stopped containers are attached to
+type StoppedAttachment struct {
+	network.Summary
+	Containers []string
+}
+
+// GetUnusedNetworks gets the list of networks no container is attached to
+// Networks are matched by ID, from the endpoints of the containers and of the networks themselves
+// Unless ignoreStopped is set, networks stopped containers are attached to are in use, and returned apart
+func (d *DockerClient) GetUnusedNetworks(ignoreStopped bool) ([]network.Summary, []StoppedAttachment, error) {
 	// Get all networks
 	networks, err := d.client.NetworkList(d.ctx, network.ListOptions{})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Get all containers to see which networks are used
 	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{All: true})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Map the names of used networks
+	// Map the IDs of used networks, and of the networks only stopped containers use
 	usedNetworks := make(map[string]bool)
+	stoppedAttachments := make(map[string][]string)
 	for _, container := range containers {
+		if !isActive(container) && ignoreStopped {
+			continue
+		}
+
 		containerInfo, err := d.client.ContainerInspect(d.ctx, container.ID)
-		if err != nil {
+		if err != nil || containerInfo.NetworkSettings == nil {
 			continue
 		}
 
-		for networkName := range containerInfo.NetworkSettings.Networks {
-			usedNetworks[networkName] = true
+		for networkName, endpoint := range containerInfo.NetworkSettings.Networks {
+			// Endpoints of containers created before the network ID was recorded only have the name
+			id := networkName
+			if endpoint != nil && endpoint.NetworkID != "" {
+				id = endpoint.NetworkID
+			}
+			if isActive(container) {
+				usedNetworks[id] = true
+			} else {
+				stoppedAttachments[id] = append(stoppedAttachments[id], strings.TrimPrefix(containerInfo.Name, "/"))
+			}
 		}
 	}
 
-	// Add default networks that should not be removed
-	defaultNetworks := []string{"bridge", "host", "none"}
-	for _, network := range defaultNetworks {
-		usedNetworks[network] = true
+	// Add default and swarm system networks that should not be removed
+	defaultNetworks := append([]string{"bridge", "host", "none"}, SwarmNetworks...)
+	isDefault := func(name string) bool {
+		for _, defaultNetwork := range defaultNetworks {
+			if name == defaultNetwork {
+				return true
+			}
+		}
+		return false
 	}
 
 	// Filter unused networks
 	var unusedNetworks []network.Summary
-	for _, network := range networks {
-		if !usedNetworks[network.Name] {
-			unusedNetworks = append(unusedNetworks, network)
+	var kept []StoppedAttachment
+	for _, net := range networks {
+		if usedNetworks[net.ID] || usedNetworks[net.Name] || net.Ingress || isDefault(net.Name) {
+			continue
+		}
+
+		// The endpoints of the network also cover containers of other nodes and attachments the containers miss
+		info, err := d.client.NetworkInspect(d.ctx, net.ID, network.InspectOptions{})
+		if errdefs.IsNotFound(err) {
+			// Removed since it was listed
+			continue
 		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(info.Containers) > 0 {
+			continue
+		}
+
+		if names := append(stoppedAttachments[net.ID], stoppedAttachments[net.Name]...); len(names) > 0 {
+			kept = append(kept, StoppedAttachment{Summary: net, Containers: names})
+			continue
+		}
+		unusedNetworks = append(unusedNetworks, net)
 	}
 
-	return unusedNetworks, nil
+	return unusedNetworks, kept, nil
+}
+
+// RemoveNetwork removes a single network
+func (d *DockerClient) RemoveNetwork(networkID string) error {
+	return d.client.NetworkRemove(d.ctx, networkID)
 }
 
 // PruneNetworks removes unused networks
@@ -228,8 +406,8 @@ func (d *DockerClient) PruneNetworks() (network.PruneReport, error) {
 	return d.client.NetworksPrune(d.ctx, pruneFilters)
 }
 
-// GetUnusedBuilds gets the list of unused Docker builds
-func (d *DockerClient) GetUnusedBuilds() ([]types.BuildCache, error) {
+// GetUnusedBuilds gets the list of unused Docker builds matching the selection
+func (d *DockerClient) GetUnusedBuilds(selection BuildSelection) ([]types.BuildCache, error) {
 	// Get disk usage which includes build cache information
 	diskUsage, err := d.GetDiskUsage()
 	if err != nil {
@@ -239,7 +417,7 @@ func (d *DockerClient) GetUnusedBuilds() ([]types.BuildCache, error) {
 	// Filter unused build caches
 	var builds []types.BuildCache
 	for _, cache := range diskUsage.BuildCache {
-		if !cache.InUse {
+		if !cache.InUse && selection.matches(*cache) {
 			builds = append(builds, *cache)
 		}
 	}
@@ -247,17 +425,32 @@ func (d *DockerClient) GetUnusedBuilds() ([]types.BuildCache, error) {
 	return builds, nil
 }
 
-// PruneBuilds removes unused Docker builds
-func (d *DockerClient) PruneBuilds(olderThan int) (*types.BuildCachePruneReport, error) {
-	pruneFilters := filters.NewArgs()
-
-	if olderThan > 0 {
-		timestamp := time.Now().Add(-time.Hour * 24 * time.Duration(olderThan)).Format(time.RFC3339)
-		pruneFilters.Add("until", timestamp)
-	}
-
+// PruneBuilds removes the unused Docker builds matching the selection
+// The selection must be expressible as prune filters, see BuildSelection.Prunable
+func (d *DockerClient) PruneBuilds(selection BuildSelection) (*types.BuildCachePruneReport, error) {
 	return d.client.BuildCachePrune(d.ctx, types.BuildCachePruneOptions{
 		All:     true, // This will prune all unused build cache
-		Filters: pruneFilters,
+		Filters: selection.filters(),
 	})
 }
+
+// RemoveBuilds removes the given Docker build cache records
+// The daemon accepts a single id filter per prune, so records are removed one at a time
+func (d *DockerClient) RemoveBuilds(ids []string) (*types.BuildCachePruneReport, error) {
+	report := &types.BuildCachePruneReport{}
+	for _, id := range ids {
+		pruneFilters := filters.NewArgs()
+		pruneFilters.Add("id", id)
+
+		removed, err := d.client.BuildCachePrune(d.ctx, types.BuildCachePruneOptions{
+			All:     true,
+			Filters: pruneFilters,
+		})
+		if err != nil {
+			return report, err
+		}
+		report.CachesDeleted = append(report.CachesDeleted, removed.CachesDeleted...)
+		report.SpaceReclaimed += removed.SpaceReclaimed
+	}
+	return report, nil
+}
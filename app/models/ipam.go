@@ -0,0 +1,179 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	"github.com/docker/docker/api/types/network"
+)
+
+// AddressPool is a range of addresses the daemon splits into subnets of Size bits for the networks it creates
+type AddressPool struct {
+	Base netip.Prefix
+	Size int
+}
+
+// DefaultAddressPools are the local pools of the daemon when default-address-pools is not configured
+var DefaultAddressPools = []AddressPool{
+	{netip.MustParsePrefix("172.17.0.0/16"), 16},
+	{netip.MustParsePrefix("172.18.0.0/16"), 16},
+	{netip.MustParsePrefix("172.19.0.0/16"), 16},
+	{netip.MustParsePrefix("172.20.0.0/14"), 16},
+	{netip.MustParsePrefix("172.24.0.0/14"), 16},
+	{netip.MustParsePrefix("172.28.0.0/14"), 16},
+	{netip.MustParsePrefix("192.168.0.0/16"), 20},
+}
+
+// String returns the pool as shown in the daemon configuration
+func (p AddressPool) String() string {
+	return fmt.Sprintf("%s /%d", p.Base, p.Size)
+}
+
+// Subnets returns the number of subnets the pool is split into
+func (p AddressPool) Subnets() int {
+	return 1 << (p.Size - p.Base.Bits())
+}
+
+// slots returns the indexes of the subnets of the pool a prefix overlaps
+func (p AddressPool) slots(prefix netip.Prefix) []int {
+	if !prefix.Addr().Is4() || !p.Base.Overlaps(prefix) {
+		return nil
+	}
+	base, first, last := ipv4Range(p.Base)
+	_, prefixFirst, prefixLast := ipv4Range(prefix)
+	start, end := max(first, prefixFirst), min(last, prefixLast)
+
+	var slots []int
+	for slot := (start - base) >> (32 - p.Size); slot <= (end-base)>>(32-p.Size); slot++ {
+		slots = append(slots, int(slot))
+	}
+	return slots
+}
+
+// ipv4Range returns the network address of an IPv4 prefix, which is its first address, and its last address
+func ipv4Range(prefix netip.Prefix) (base, first, last uint32) {
+	addr := prefix.Masked().Addr().As4()
+	base = binary.BigEndian.Uint32(addr[:])
+	return base, base, base | (1<<(32-prefix.Bits()) - 1)
+}
+
+// PoolUsage is an address pool with the subnets networks use
+type PoolUsage struct {
+	AddressPool
+	Used int
+}
+
+// Free returns the number of subnets of the pool no network uses
+func (p PoolUsage) Free() int {
+	return p.Subnets() - p.Used
+}
+
+// NetworkSubnet is an IPv4 subnet of a network, with the address pool it consumes, empty when it is outside the pools
+type NetworkSubnet struct {
+	network.Summary
+	Subnet netip.Prefix
+	Pool   string
+}
+
+// IPAMUsage shows how the networks consume the address pools of the daemon
+type IPAMUsage struct {
+	Pools    []PoolUsage
+	Networks []NetworkSubnet
+	// Configured is false when the daemon uses its default address pools
+	Configured bool
+}
+
+// Free returns the number of subnets left in all the address pools
+func (u *IPAMUsage) Free() int {
+	free := 0
+	for _, pool := range u.Pools {
+		free += pool.Free()
+	}
+	return free
+}
+
+// Release removes a network from the usage and returns the number of subnets this frees,
+// which is 0 when it is outside the pools or other networks overlap its subnets
+func (u *IPAMUsage) Release(networkID string) int {
+	before := u.Free()
+	networks := u.Networks[:0:0]
+	for _, subnet := range u.Networks {
+		if subnet.ID != networkID {
+			networks = append(networks, subnet)
+		}
+	}
+	u.Networks = networks
+	u.count()
+	return u.Free() - before
+}
+
+// count computes the subnets used in each pool and the pool each network consumes
+func (u *IPAMUsage) count() {
+	for i := range u.Pools {
+		pool := &u.Pools[i]
+		used := make(map[int]bool)
+		for j := range u.Networks {
+			slots := pool.slots(u.Networks[j].Subnet)
+			if len(slots) > 0 && u.Networks[j].Pool == "" {
+				u.Networks[j].Pool = pool.Base.String()
+			}
+			for _, slot := range slots {
+				used[slot] = true
+			}
+		}
+		pool.Used = len(used)
+	}
+}
+
+// GetAddressPools returns the local address pools of the daemon, and whether they are configured or the defaults
+func (d *DockerClient) GetAddressPools() ([]AddressPool, bool, error) {
+	info, err := d.client.Info(d.ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(info.DefaultAddressPools) == 0 {
+		return DefaultAddressPools, false, nil
+	}
+
+	pools := make([]AddressPool, 0, len(info.DefaultAddressPools))
+	for _, pool := range info.DefaultAddressPools {
+		base, err := netip.ParsePrefix(pool.Base)
+		if err != nil {
+			return nil, false, fmt.Errorf("address pool %s: %v", pool.Base, err)
+		}
+		if !base.Addr().Is4() || pool.Size < base.Bits() || pool.Size > 32 {
+			continue
+		}
+		pools = append(pools, AddressPool{Base: base, Size: pool.Size})
+	}
+	return pools, true, nil
+}
+
+// GetIPAMUsage returns the IPv4 subnets of the networks and the subnets they use in the address pools of the daemon
+func (d *DockerClient) GetIPAMUsage() (*IPAMUsage, error) {
+	pools, configured, err := d.GetAddressPools()
+	if err != nil {
+		return nil, err
+	}
+	networks, err := d.client.NetworkList(d.ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &IPAMUsage{Configured: configured}
+	for _, pool := range pools {
+		usage.Pools = append(usage.Pools, PoolUsage{AddressPool: pool})
+	}
+	for _, net := range networks {
+		for _, config := range net.IPAM.Config {
+			subnet, err := netip.ParsePrefix(config.Subnet)
+			if err != nil || !subnet.Addr().Is4() {
+				continue
+			}
+			usage.Networks = append(usage.Networks, NetworkSubnet{Summary: net, Subnet: subnet.Masked()})
+		}
+	}
+	usage.count()
+	return usage, nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// Reasons a plugin is selected for removal
+const (
+	PluginDisabled     = "disabled"
+	PluginUnusedDriver = "driver used by no volume or network"
+)
+
+// driverCapabilities are the plugin capabilities whose use can be checked from the volumes and networks
+var driverCapabilities = map[string]bool{
+	"volumedriver":  true,
+	"networkdriver": true,
+}
+
+// UnusedPlugin is a managed plugin selected for removal, with the reason why
+type UnusedPlugin struct {
+	types.Plugin
+	Reason string
+}
+
+// pluginName returns the name of a plugin as volumes and networks refer to it, without the latest tag
+func pluginName(name string) string {
+	return strings.TrimSuffix(name, ":latest")
+}
+
+// GetUnusedPlugins returns the disabled plugins and the enabled volume and network driver plugins no volume or network uses
+// Enabled plugins with any other capability, such as logging or authorization, are never selected
+func (d *DockerClient) GetUnusedPlugins() ([]UnusedPlugin, error) {
+	plugins, err := d.client.PluginList(d.ctx, filters.NewArgs())
+	if err != nil {
+		return nil, err
+	}
+
+	volumes, err := d.client.VolumeList(d.ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	networks, err := d.client.NetworkList(d.ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	usedDrivers := make(map[string]bool)
+	for _, vol := range volumes.Volumes {
+		usedDrivers[pluginName(vol.Driver)] = true
+	}
+	for _, net := range networks {
+		usedDrivers[pluginName(net.Driver)] = true
+	}
+
+	var unusedPlugins []UnusedPlugin
+	for _, plugin := range plugins {
+		// A disabled plugin still backing a volume or network would leave it unusable for good
+		if usedDrivers[pluginName(plugin.Name)] {
+			continue
+		}
+
+		if !plugin.Enabled {
+			unusedPlugins = append(unusedPlugins, UnusedPlugin{Plugin: *plugin, Reason: PluginDisabled})
+			continue
+		}
+
+		capabilities := plugin.Config.Interface.Types
+		driverOnly := len(capabilities) > 0
+		for _, capability := range capabilities {
+			if !driverCapabilities[capability.Capability] {
+				driverOnly = false
+			}
+		}
+		if driverOnly {
+			unusedPlugins = append(unusedPlugins, UnusedPlugin{Plugin: *plugin, Reason: PluginUnusedDriver})
+		}
+	}
+
+	return unusedPlugins, nil
+}
+
+// RemovePlugin removes a plugin, disabling it first when it is enabled
+// Disabling fails when the plugin is in use, in which case it is not removed
+func (d *DockerClient) RemovePlugin(plugin types.Plugin) error {
+	if plugin.Enabled {
+		if err := d.client.PluginDisable(d.ctx, plugin.Name, types.PluginDisableOptions{}); err != nil {
+			return err
+		}
+	}
+	return d.client.PluginRemove(d.ctx, plugin.Name, types.PluginRemoveOptions{})
+}
@@ -0,0 +1,211 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// Labels Docker Compose sets on the resources of a project
+const (
+	ComposeProjectLabel     = "com.docker.compose.project"
+	ComposeWorkingDirLabel  = "com.docker.compose.project.working_dir"
+	ComposeConfigFilesLabel = "com.docker.compose.project.config_files"
+)
+
+// States of a Compose project
+const (
+	ProjectRunning          = "running"
+	ProjectPartiallyStopped = "partially stopped"
+	ProjectStopped          = "stopped"
+)
+
+// Project groups the resources of a Docker Compose project
+type Project struct {
+	Name       string
+	Containers []types.Container
+	Networks   []network.Summary
+	Volumes    []volume.Volume
+	Images     []image.Summary
+	// Running is the number of active containers: running, paused or restarting
+	Running int
+	// LastActivity is the most recent creation, start or stop of one of its containers
+	LastActivity time.Time
+	// WorkingDir and ConfigFiles locate the Compose files the project was created from
+	WorkingDir  string
+	ConfigFiles []string
+	// Orphaned explains why the project no longer exists on disk, empty when it does
+	Orphaned string
+}
+
+// State returns whether the containers of the project are running, partially stopped or stopped
+func (p Project) State() string {
+	switch {
+	case p.Running == 0:
+		return ProjectStopped
+	case p.Running < len(p.Containers):
+		return ProjectPartiallyStopped
+	default:
+		return ProjectRunning
+	}
+}
+
+// touch records activity on the project
+func (p *Project) touch(t time.Time) {
+	if t.After(p.LastActivity) {
+		p.LastActivity = t
+	}
+}
+
+// locate records the working directory and Compose files of the project from the labels of one of its resources
+func (p *Project) locate(labels map[string]string) {
+	if p.WorkingDir == "" {
+		p.WorkingDir = labels[ComposeWorkingDirLabel]
+	}
+	if p.ConfigFiles == nil && labels[ComposeConfigFilesLabel] != "" {
+		for _, file := range strings.Split(labels[ComposeConfigFilesLabel], ",") {
+			if !filepath.IsAbs(file) && p.WorkingDir != "" {
+				file = filepath.Join(p.WorkingDir, file)
+			}
+			p.ConfigFiles = append(p.ConfigFiles, file)
+		}
+	}
+}
+
+// missing reports whether a path was deleted, rather than being out of reach
+// The parent directory must exist, so that a tool running without access to the host
+// filesystem does not take every project for an orphan
+func missing(path string) bool {
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	_, err := os.Stat(filepath.Dir(path))
+	return err == nil
+}
+
+// detectOrphan checks whether the working directory or the Compose files of the project were deleted
+func (p *Project) detectOrphan() {
+	if p.WorkingDir != "" && missing(p.WorkingDir) {
+		p.Orphaned = fmt.Sprintf("working directory %s no longer exists", p.WorkingDir)
+		return
+	}
+
+	if len(p.ConfigFiles) == 0 {
+		return
+	}
+	for _, file := range p.ConfigFiles {
+		if !missing(file) {
+			return
+		}
+	}
+	p.Orphaned = fmt.Sprintf("compose files %s no longer exist", strings.Join(p.ConfigFiles, ", "))
+}
+
+// ProjectName returns the Compose project of a resource from its labels, empty when it has none
+func ProjectName(labels map[string]string) string {
+	return labels[ComposeProjectLabel]
+}
+
+// parseTime parses a timestamp of the inspect API, returning the zero time for unset ones
+func parseTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || t.Year() <= 1 {
+		return time.Time{}
+	}
+	return t
+}
+
+// GetProjects groups containers, networks, volumes and images by Compose project, sorted by name
+func (d *DockerClient) GetProjects() ([]Project, error) {
+	projects := make(map[string]*Project)
+	project := func(labels map[string]string) *Project {
+		name := ProjectName(labels)
+		if name == "" {
+			return nil
+		}
+		if projects[name] == nil {
+			projects[name] = &Project{Name: name}
+		}
+		projects[name].locate(labels)
+		return projects[name]
+	}
+
+	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		p := project(c.Labels)
+		if p == nil {
+			continue
+		}
+		p.Containers = append(p.Containers, c)
+		if isActive(c) {
+			p.Running++
+		}
+
+		activity := time.Unix(c.Created, 0)
+		if info, err := d.client.ContainerInspect(d.ctx, c.ID); err == nil && info.State != nil {
+			for _, t := range []time.Time{parseTime(info.State.StartedAt), parseTime(info.State.FinishedAt)} {
+				if t.After(activity) {
+					activity = t
+				}
+			}
+		}
+		if isActive(c) {
+			activity = time.Now()
+		}
+		p.touch(activity)
+	}
+
+	networks, err := d.client.NetworkList(d.ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		if p := project(n.Labels); p != nil {
+			p.Networks = append(p.Networks, n)
+			p.touch(n.Created)
+		}
+	}
+
+	volumes, err := d.client.VolumeList(d.ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range volumes.Volumes {
+		if p := project(v.Labels); p != nil {
+			p.Volumes = append(p.Volumes, *v)
+			p.touch(parseTime(v.CreatedAt))
+		}
+	}
+
+	images, err := d.client.ImageList(d.ctx, image.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range images {
+		if p := project(img.Labels); p != nil {
+			p.Images = append(p.Images, img)
+		}
+	}
+
+	result := make([]Project, 0, len(projects))
+	for _, p := range projects {
+		p.detectOrphan()
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
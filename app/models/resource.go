@@ -0,0 +1,240 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/api/types/volume"
+)
+
+// ShortID returns the 12 characters an ID is shown with, without the sha256: prefix of image IDs
+func ShortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	return id[:min(12, len(id))]
+}
+
+// Resource is a kind-agnostic view of a Docker object, used to apply selection rules
+type Resource struct {
+	Kind       string
+	ID         string
+	Name       string
+	Size       int64
+	Created    time.Time
+	Labels     map[string]string
+	State      string
+	ExitCode   int
+	References int
+	// LastWrite is when the content of the resource was last modified, zero when unknown
+	LastWrite time.Time
+	// Finished is when a container last exited, zero when it never ran
+	Finished time.Time
+	// Lifecycle is the lifecycle class of a stopped container
+	Lifecycle string
+	// Aliases are the other names the resource can be referred to by, such as the tags and digests of an image
+	Aliases []string
+}
+
+// Kept is a resource left out of the selection, with the reason why
+type Kept struct {
+	Resource Resource
+	Reason   string
+}
+
+// Idle returns how long ago the content of the resource was last modified, zero when unknown
+func (r Resource) Idle() time.Duration {
+	if r.LastWrite.IsZero() {
+		return 0
+	}
+	return time.Since(r.LastWrite)
+}
+
+// Stopped returns how long ago a container last exited, zero when it never ran
+func (r Resource) Stopped() time.Duration {
+	if r.Finished.IsZero() {
+		return 0
+	}
+	return time.Since(r.Finished)
+}
+
+// Age returns how long ago the resource was created
+func (r Resource) Age() time.Duration {
+	if r.Created.IsZero() {
+		return 0
+	}
+	return time.Since(r.Created)
+}
+
+// ContainerResource describes a stopped container as a Resource
+func ContainerResource(c StoppedContainer) Resource {
+	name := c.ID
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	return Resource{
+		Kind:      "container",
+		ID:        c.ID,
+		Name:      name,
+		Size:      c.SizeRw,
+		Created:   time.Unix(c.Created, 0),
+		Labels:    c.Labels,
+		State:     c.State,
+		ExitCode:  c.ExitCode,
+		Finished:  c.FinishedAt,
+		Lifecycle: c.Lifecycle(),
+	}
+}
+
+// ImageResource describes an image as a Resource, named after its first tag
+func ImageResource(img image.Summary) Resource {
+	name := img.ID
+	if len(img.RepoTags) > 0 {
+		name = img.RepoTags[0]
+	}
+
+	return Resource{
+		Kind:       "image",
+		ID:         img.ID,
+		Name:       name,
+		Size:       img.Size,
+		Created:    time.Unix(img.Created, 0),
+		Labels:     img.Labels,
+		References: max(int(img.Containers), 0),
+		Aliases:    append(append([]string{}, img.RepoTags...), img.RepoDigests...),
+	}
+}
+
+// VolumeResource describes a volume as a Resource
+func VolumeResource(vol volume.Volume) Resource {
+	created, _ := time.Parse(time.RFC3339, vol.CreatedAt)
+
+	resource := Resource{
+		Kind:    "volume",
+		ID:      vol.Name,
+		Name:    vol.Name,
+		Created: created,
+		Labels:  vol.Labels,
+		State:   "named",
+	}
+	if IsAnonymousVolume(vol) {
+		resource.State = "anonymous"
+	}
+	if vol.UsageData != nil {
+		resource.Size = max(vol.UsageData.Size, 0)
+		resource.References = max(int(vol.UsageData.RefCount), 0)
+	}
+	return resource
+}
+
+// UnusedVolumeResource describes an unused volume as a Resource, with its last write
+func UnusedVolumeResource(vol UnusedVolume) Resource {
+	resource := VolumeResource(vol.Volume)
+	resource.LastWrite = vol.LastWrite
+	return resource
+}
+
+// NetworkResource describes a network as a Resource
+func NetworkResource(net network.Summary) Resource {
+	return Resource{
+		Kind:       "network",
+		ID:         net.ID,
+		Name:       net.Name,
+		Created:    net.Created,
+		Labels:     net.Labels,
+		References: len(net.Containers),
+	}
+}
+
+// BuildResource describes a build cache record as a Resource
+func BuildResource(build types.BuildCache) Resource {
+	state := ""
+	if build.InUse {
+		state = "in-use"
+	}
+
+	return Resource{
+		Kind:       "build",
+		ID:         build.ID,
+		Name:       build.Description,
+		Size:       build.Size,
+		Created:    build.CreatedAt,
+		State:      state,
+		References: build.UsageCount,
+	}
+}
+
+// ConfigResource describes a swarm config as a Resource
+func ConfigResource(config swarm.Config) Resource {
+	return Resource{
+		Kind:    "config",
+		ID:      config.ID,
+		Name:    config.Spec.Name,
+		Size:    int64(len(config.Spec.Data)),
+		Created: config.CreatedAt,
+		Labels:  config.Spec.Labels,
+	}
+}
+
+// SecretResource describes a swarm secret as a Resource
+func SecretResource(secret swarm.Secret) Resource {
+	return Resource{
+		Kind:    "secret",
+		ID:      secret.ID,
+		Name:    secret.Spec.Name,
+		Created: secret.CreatedAt,
+		Labels:  secret.Spec.Labels,
+	}
+}
+
+// PluginResource describes a managed plugin as a Resource
+// Plugins have no creation time, so age filters do not apply to them
+func PluginResource(plugin UnusedPlugin) Resource {
+	state := "disabled"
+	if plugin.Enabled {
+		state = "enabled"
+	}
+
+	return Resource{
+		Kind:  "plugin",
+		ID:    plugin.ID,
+		Name:  plugin.Name,
+		State: state,
+	}
+}
+
+// ServiceResource describes a scaled down swarm service as a Resource
+// Its age is the time since it was last updated, which is when it was scaled down for services nobody touched since
+func ServiceResource(service ScaledDownService) Resource {
+	return Resource{
+		Kind:    "service",
+		ID:      service.ID,
+		Name:    service.Spec.Name,
+		Created: service.UpdatedAt,
+		Labels:  service.Spec.Labels,
+	}
+}
+
+// BuilderResource describes a buildx builder node as a Resource
+// Its age is the time since its last activity
+func BuilderResource(builder Builder) Resource {
+	resource := Resource{
+		Kind:    "builder",
+		ID:      builder.Volume,
+		Name:    builder.Name,
+		Size:    builder.Size,
+		Created: builder.LastActivity,
+		State:   "stopped",
+	}
+	if builder.Container != nil {
+		resource.ID = builder.Container.ID
+		resource.Labels = builder.Container.Labels
+	}
+	if builder.Running {
+		resource.State = "running"
+	}
+	return resource
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/image"
+)
+
+// Orders in which the tags of a repository are ranked for keep-last retention
+const (
+	OrderTime   = "time"
+	OrderSemver = "semver"
+)
+
+// SplitTag splits an image reference such as registry:5000/app:1.2 into its repository and tag
+func SplitTag(ref string) (repository, tag string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || i < strings.LastIndex(ref, "/") {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+// semver is a parsed semantic version tag
+type semver struct {
+	numbers    [3]int
+	prerelease string
+}
+
+// parseSemver parses tags such as 1.2.3, v1.2 or 2.0.0-rc.1, ignoring build metadata
+func parseSemver(tag string) (semver, bool) {
+	var v semver
+
+	tag = strings.TrimPrefix(tag, "v")
+	tag, _, _ = strings.Cut(tag, "+")
+	tag, v.prerelease, _ = strings.Cut(tag, "-")
+
+	parts := strings.Split(tag, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.numbers[i] = n
+	}
+	return v, true
+}
+
+// less reports whether v precedes w, a prerelease preceding its release
+func (v semver) less(w semver) bool {
+	for i := range v.numbers {
+		if v.numbers[i] != w.numbers[i] {
+			return v.numbers[i] < w.numbers[i]
+		}
+	}
+	switch {
+	case v.prerelease == w.prerelease:
+		return false
+	case v.prerelease == "":
+		return false
+	case w.prerelease == "":
+		return true
+	default:
+		return v.prerelease < w.prerelease
+	}
+}
+
+// taggedImage is one tag of an image, as ranked within its repository
+type taggedImage struct {
+	tag     string
+	imageID string
+	created int64
+}
+
+// keepLastImages returns the IDs of the images holding one of the n most recent tags of their repository
+// Only repositories matching one of the patterns are considered, or all of them when there is none
+// Repositories that are not considered keep all their images
+func keepLastImages(images []image.Summary, n int, order string, patterns []string) map[string]bool {
+	kept := make(map[string]bool)
+
+	repositories := make(map[string][]taggedImage)
+	for _, img := range images {
+		for _, ref := range img.RepoTags {
+			repository, tag := SplitTag(ref)
+			if tag == "" || repository == "<none>" {
+				continue
+			}
+			repositories[repository] = append(repositories[repository], taggedImage{tag, img.ID, img.Created})
+		}
+	}
+
+	for repository, tags := range repositories {
+		if !matchesAny(repository, patterns) {
+			for _, t := range tags {
+				kept[t.imageID] = true
+			}
+			continue
+		}
+
+		sort.SliceStable(tags, func(i, j int) bool {
+			return newer(tags[i], tags[j], order)
+		})
+		for i := 0; i < n && i < len(tags); i++ {
+			kept[tags[i].imageID] = true
+		}
+	}
+
+	return kept
+}
+
+// newer reports whether a ranks before b, most recent first
+// With the semver order, semantic versions rank before any other tag, which fall back to creation time
+func newer(a, b taggedImage, order string) bool {
+	if order == OrderSemver {
+		va, okA := parseSemver(a.tag)
+		vb, okB := parseSemver(b.tag)
+		switch {
+		case okA && okB:
+			if va != vb {
+				return vb.less(va)
+			}
+		case okA != okB:
+			return okA
+		}
+	}
+	return a.created > b.created
+}
+
+// matchesAny reports whether name matches one of the patterns, or whether there are no patterns
+func matchesAny(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+	}
+	return false
+}
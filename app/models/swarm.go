@@ -0,0 +1,250 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// SwarmNetworks are the system networks of a swarm, which are never removed
+var SwarmNetworks = []string{"ingress", "docker_gwbridge"}
+
+// ServiceReferences maps the images and networks used by swarm services to the names of these services
+type ServiceReferences struct {
+	// Images is keyed by the image references of the service and task specs, as name:tag or name@digest
+	Images map[string][]string
+	// Networks is keyed by network ID, and by name for networks attached by name
+	Networks map[string][]string
+}
+
+// add records that a service uses a resource
+func add(refs map[string][]string, key string, service string) {
+	if key == "" {
+		return
+	}
+	for _, name := range refs[key] {
+		if name == service {
+			return
+		}
+	}
+	refs[key] = append(refs[key], service)
+}
+
+// addImage records that a service uses an image
+// Service specs pin images as name:tag@digest, which is recorded both as name:tag and name@digest
+func addImage(refs map[string][]string, ref string, service string) {
+	name, digest, pinned := strings.Cut(ref, "@")
+	add(refs, name, service)
+	if pinned {
+		repository, _ := SplitTag(name)
+		add(refs, repository+"@"+digest, service)
+	}
+}
+
+// IsSwarmManager reports whether the daemon is a manager of an active swarm
+func (d *DockerClient) IsSwarmManager() (bool, error) {
+	info, err := d.client.Info(d.ctx)
+	if err != nil {
+		return false, err
+	}
+	return info.Swarm.LocalNodeState == swarm.LocalNodeStateActive && info.Swarm.ControlAvailable, nil
+}
+
+// GetServiceReferences returns the images and networks referenced by the services and tasks of the swarm
+// It must only be called on a swarm manager
+func (d *DockerClient) GetServiceReferences() (*ServiceReferences, error) {
+	refs := &ServiceReferences{
+		Images:   make(map[string][]string),
+		Networks: make(map[string][]string),
+	}
+
+	services, err := d.client.ServiceList(d.ctx, types.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	for _, service := range services {
+		name := service.Spec.Name
+		names[service.ID] = name
+
+		if spec := service.Spec.TaskTemplate.ContainerSpec; spec != nil {
+			addImage(refs.Images, spec.Image, name)
+		}
+		for _, network := range service.Spec.TaskTemplate.Networks {
+			add(refs.Networks, network.Target, name)
+		}
+		for _, vip := range service.Endpoint.VirtualIPs {
+			add(refs.Networks, vip.NetworkID, name)
+		}
+	}
+
+	// Tasks also reference the images and networks of previous versions of their service
+	tasks, err := d.client.TaskList(d.ctx, types.TaskListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		name := names[task.ServiceID]
+		if name == "" {
+			name = task.ServiceID
+		}
+
+		if spec := task.Spec.ContainerSpec; spec != nil {
+			addImage(refs.Images, spec.Image, name)
+		}
+		for _, attachment := range task.NetworksAttachments {
+			add(refs.Networks, attachment.Network.ID, name)
+		}
+	}
+
+	return refs, nil
+}
+
+// serviceSpecs returns the current and previous specs of the services, as a rollback restores the previous one
+func serviceSpecs(services []swarm.Service) []swarm.ServiceSpec {
+	var specs []swarm.ServiceSpec
+	for _, service := range services {
+		specs = append(specs, service.Spec)
+		if service.PreviousSpec != nil {
+			specs = append(specs, *service.PreviousSpec)
+		}
+	}
+	return specs
+}
+
+// GetUnusedConfigs returns the swarm configs no service spec references
+// It must only be called on a swarm manager
+func (d *DockerClient) GetUnusedConfigs() ([]swarm.Config, error) {
+	configs, err := d.client.ConfigList(d.ctx, types.ConfigListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := d.client.ServiceList(d.ctx, types.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	usedConfigs := make(map[string]bool)
+	for _, spec := range serviceSpecs(services) {
+		if spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+		for _, ref := range spec.TaskTemplate.ContainerSpec.Configs {
+			usedConfigs[ref.ConfigID] = true
+		}
+	}
+
+	var unusedConfigs []swarm.Config
+	for _, config := range configs {
+		if !usedConfigs[config.ID] {
+			unusedConfigs = append(unusedConfigs, config)
+		}
+	}
+	return unusedConfigs, nil
+}
+
+// GetUnusedSecrets returns the swarm secrets no service spec references
+// It must only be called on a swarm manager
+func (d *DockerClient) GetUnusedSecrets() ([]swarm.Secret, error) {
+	secrets, err := d.client.SecretList(d.ctx, types.SecretListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	services, err := d.client.ServiceList(d.ctx, types.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	usedSecrets := make(map[string]bool)
+	for _, spec := range serviceSpecs(services) {
+		if spec.TaskTemplate.ContainerSpec == nil {
+			continue
+		}
+		for _, ref := range spec.TaskTemplate.ContainerSpec.Secrets {
+			usedSecrets[ref.SecretID] = true
+		}
+	}
+
+	var unusedSecrets []swarm.Secret
+	for _, secret := range secrets {
+		if !usedSecrets[secret.ID] {
+			unusedSecrets = append(unusedSecrets, secret)
+		}
+	}
+	return unusedSecrets, nil
+}
+
+// RemoveConfig removes a swarm config
+func (d *DockerClient) RemoveConfig(id string) error {
+	return d.client.ConfigRemove(d.ctx, id)
+}
+
+// RemoveSecret removes a swarm secret
+func (d *DockerClient) RemoveSecret(id string) error {
+	return d.client.SecretRemove(d.ctx, id)
+}
+
+// ScaledDownService is a replicated service scaled to zero, with the resources it keeps in use
+type ScaledDownService struct {
+	swarm.Service
+	Image    string
+	Networks []string
+	Configs  []string
+	Secrets  []string
+}
+
+// GetScaledDownServices returns the replicated services scaled to zero replicas
+// It must only be called on a swarm manager
+func (d *DockerClient) GetScaledDownServices() ([]ScaledDownService, error) {
+	services, err := d.client.ServiceList(d.ctx, types.ServiceListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	networks, err := d.client.NetworkList(d.ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	networkNames := make(map[string]string)
+	for _, net := range networks {
+		networkNames[net.ID] = net.Name
+	}
+
+	var scaledDown []ScaledDownService
+	for _, service := range services {
+		replicated := service.Spec.Mode.Replicated
+		if replicated == nil || replicated.Replicas == nil || *replicated.Replicas != 0 {
+			continue
+		}
+
+		s := ScaledDownService{Service: service}
+		for _, attachment := range service.Spec.TaskTemplate.Networks {
+			name := attachment.Target
+			if networkNames[name] != "" {
+				name = networkNames[name]
+			}
+			s.Networks = append(s.Networks, name)
+		}
+		if spec := service.Spec.TaskTemplate.ContainerSpec; spec != nil {
+			s.Image, _, _ = strings.Cut(spec.Image, "@")
+			for _, ref := range spec.Configs {
+				s.Configs = append(s.Configs, ref.ConfigName)
+			}
+			for _, ref := range spec.Secrets {
+				s.Secrets = append(s.Secrets, ref.SecretName)
+			}
+		}
+		scaledDown = append(scaledDown, s)
+	}
+	return scaledDown, nil
+}
+
+// RemoveService removes a swarm service
+func (d *DockerClient) RemoveService(id string) error {
+	return d.client.ServiceRemove(d.ctx, id)
+}
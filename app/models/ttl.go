@@ -0,0 +1,166 @@
+package models
+
+import (
+	"docker-cleanup/app/units"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// TTLLabel gives a resource a lifetime from its creation, such as 2h or 3d
+const TTLLabel = "docker-cleanup.ttl"
+
+// ExpiresLabel gives a resource an absolute RFC3339 expiry time, taking precedence over TTLLabel
+const ExpiresLabel = "docker-cleanup.expires"
+
+// ExpiringResource is a resource with a TTL or expiry label
+type ExpiringResource struct {
+	Resource
+	// Expires is when the resource expires, zero when its label is invalid
+	Expires time.Time
+	// Err explains why the label of the resource is invalid
+	Err error
+	// Volume is the expiring volume, nil for the other kinds
+	Volume *volume.Volume
+	// active is true for the containers that are running, paused or restarting
+	active bool
+}
+
+// Expired reports whether the resource expired at the given time
+func (r ExpiringResource) Expired(now time.Time) bool {
+	return r.Err == nil && !r.Expires.After(now)
+}
+
+// Running reports whether the resource is a container that must be stopped before it is removed
+func (r ExpiringResource) Running() bool {
+	return r.active
+}
+
+// expiring returns a resource with the expiry time of its labels, false when it has none
+func expiring(r Resource) (ExpiringResource, bool) {
+	if value, ok := r.Labels[ExpiresLabel]; ok {
+		expires, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			err = fmt.Errorf("invalid %s label %q: expected an RFC3339 time", ExpiresLabel, value)
+		}
+		return ExpiringResource{Resource: r, Expires: expires, Err: err}, true
+	}
+	if value, ok := r.Labels[TTLLabel]; ok {
+		ttl, err := units.ParseDuration(value)
+		switch {
+		case err != nil:
+			err = fmt.Errorf("invalid %s label %q: %v", TTLLabel, value, err)
+		case r.Created.IsZero():
+			err = fmt.Errorf("%s label: unknown creation time", TTLLabel)
+		}
+		if err != nil {
+			return ExpiringResource{Resource: r, Err: err}, true
+		}
+		return ExpiringResource{Resource: r, Expires: r.Created.Add(ttl)}, true
+	}
+	return ExpiringResource{}, false
+}
+
+// GetExpiringResources returns the containers, including running ones, networks, volumes and images with a TTL or
+// expiry label, in this order so that the resources expiring with a container are no longer in use once it is removed
+// The labels containers inherit from their image are ignored, so that a TTL set on an image only expires the image
+func (d *DockerClient) GetExpiringResources() ([]ExpiringResource, error) {
+	var resources []Resource
+
+	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+	imageLabels := make(map[string]map[string]string)
+	activeContainers := make(map[string]bool)
+	for _, c := range containers {
+		activeContainers[c.ID] = isActive(c)
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		inherited, ok := imageLabels[c.ImageID]
+		if !ok {
+			info, err := d.client.ImageInspect(d.ctx, c.ImageID)
+			if err != nil && !errdefs.IsNotFound(err) {
+				return nil, err
+			}
+			// The labels of a container whose image is gone are all its own
+			if info.Config != nil {
+				inherited = info.Config.Labels
+			}
+			imageLabels[c.ImageID] = inherited
+		}
+		resources = append(resources, Resource{
+			Kind:    "container",
+			ID:      c.ID,
+			Name:    name,
+			Created: time.Unix(c.Created, 0),
+			Labels:  ownLabels(c.Labels, inherited),
+			State:   c.State,
+		})
+	}
+
+	networks, err := d.client.NetworkList(d.ctx, network.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, net := range networks {
+		resources = append(resources, NetworkResource(net))
+	}
+
+	volumes, err := d.client.VolumeList(d.ctx, volume.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	volumesByName := make(map[string]*volume.Volume)
+	for _, vol := range volumes.Volumes {
+		resources = append(resources, VolumeResource(*vol))
+		volumesByName[vol.Name] = vol
+	}
+
+	images, err := d.client.ImageList(d.ctx, image.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range images {
+		resources = append(resources, ImageResource(img))
+	}
+
+	var expiringResources []ExpiringResource
+	for _, r := range resources {
+		if e, ok := expiring(r); ok {
+			switch r.Kind {
+			case "volume":
+				e.Volume = volumesByName[r.Name]
+			case "container":
+				e.active = activeContainers[r.ID]
+			}
+			expiringResources = append(expiringResources, e)
+		}
+	}
+	return expiringResources, nil
+}
+
+// ownLabels returns the labels of a container without the ones it inherits unchanged from its image
+func ownLabels(labels, imageLabels map[string]string) map[string]string {
+	own := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if imageValue, ok := imageLabels[key]; !ok || imageValue != value {
+			own[key] = value
+		}
+	}
+	return own
+}
+
+// StopContainer stops a container gracefully, killing it when it is still running after the timeout
+func (d *DockerClient) StopContainer(containerID string, timeout time.Duration) error {
+	seconds := int(timeout.Seconds())
+	return d.client.ContainerStop(d.ctx, containerID, container.StopOptions{Timeout: &seconds})
+}
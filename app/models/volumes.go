@@ -0,0 +1,168 @@
+package models
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/volume"
+)
+
+// AnonymousVolumeLabel is the label the daemon sets on anonymous volumes since API 1.42
+const AnonymousVolumeLabel = "com.docker.volume.anonymous"
+
+// anonymousVolumePattern matches the random names the daemon gives to anonymous volumes
+var anonymousVolumePattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IsAnonymousVolume reports whether a volume was created without a name, for a container or an image VOLUME
+// Daemons older than API 1.42 do not label them, so a random name is enough, see IsLabeledAnonymousVolume
+func IsAnonymousVolume(vol volume.Volume) bool {
+	return IsLabeledAnonymousVolume(vol) || anonymousVolumePattern.MatchString(vol.Name)
+}
+
+// IsLabeledAnonymousVolume reports whether a volume has the anonymous label, which is all a volume prune matches
+func IsLabeledAnonymousVolume(vol volume.Volume) bool {
+	_, ok := vol.Labels[AnonymousVolumeLabel]
+	return ok
+}
+
+// UnusedVolume is a volume no container uses, with what is known of its content
+type UnusedVolume struct {
+	volume.Volume
+	// LastWrite is the most recent modification time of the files of the volume,
+	// zero when its mountpoint cannot be read or until ReadLastWrite is called
+	LastWrite time.Time
+	// Remote explains why the data of the volume lives outside of the host, empty when it is local
+	Remote string
+	// Database is the database whose data files were found in the volume, empty when there are none
+	// or the mountpoint cannot be read
+	Database string
+}
+
+// DescribeVolume returns a volume with where its data lives and the database it holds
+func DescribeVolume(vol volume.Volume) UnusedVolume {
+	described := UnusedVolume{
+		Volume: vol,
+		Remote: remoteVolume(vol),
+	}
+	if vol.Mountpoint != "" {
+		described.Database = detectDatabase(vol.Mountpoint, 0)
+	}
+	return described
+}
+
+// databaseSignatures are files and directories found in the data directory of databases
+var databaseSignatures = []struct {
+	name     string
+	dir      bool
+	database string
+}{
+	{"PG_VERSION", false, "PostgreSQL"},
+	{"ibdata1", false, "MySQL/MariaDB"},
+	{"dump.rdb", false, "Redis"},
+	{"appendonly.aof", false, "Redis"},
+	{"appendonlydir", true, "Redis"},
+	{"WiredTiger", false, "MongoDB"},
+	{"nodes", true, "Elasticsearch"},
+}
+
+// maxDatabaseDepth is how deep below the mountpoint database data directories are looked for,
+// as images often mount the volume on the parent of the data directory
+const maxDatabaseDepth = 2
+
+// detectDatabase returns the database whose data files are in a directory or its subdirectories, empty when there is none
+func detectDatabase(dir string, depth int) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	for _, signature := range databaseSignatures {
+		for _, entry := range entries {
+			if entry.Name() == signature.name && entry.IsDir() == signature.dir {
+				return signature.database
+			}
+		}
+	}
+
+	if depth < maxDatabaseDepth {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			if database := detectDatabase(filepath.Join(dir, entry.Name()), depth+1); database != "" {
+				return database
+			}
+		}
+	}
+	return ""
+}
+
+// LocalVolumeDriver is the driver of the volumes stored on the host
+const LocalVolumeDriver = "local"
+
+// remoteMountTypes are the filesystem types of local driver volumes mounting a network share
+var remoteMountTypes = map[string]bool{
+	"nfs":  true,
+	"nfs4": true,
+	"cifs": true,
+	"smb":  true,
+	"smb3": true,
+}
+
+// remoteVolume explains why the data of a volume lives outside of the host, empty when it is local
+// Volumes of other drivers than local, and local volumes mounting a network share, may hold shared remote data
+func remoteVolume(vol volume.Volume) string {
+	if vol.Driver != LocalVolumeDriver {
+		return "driver " + vol.Driver
+	}
+	if mountType := strings.ToLower(vol.Options["type"]); remoteMountTypes[mountType] {
+		return mountType + " mount"
+	}
+	return ""
+}
+
+// ReadLastWrite sets the last write of the volume, walking the files under its mountpoint
+// When after is not zero, the walk stops at the first file modified after it, which is then taken for the last write
+func (v *UnusedVolume) ReadLastWrite(after time.Time) {
+	v.LastWrite = lastWrite(v.Mountpoint, after)
+}
+
+// lastWrite returns the most recent modification time under a volume mountpoint, zero when it cannot be read
+// Unreadable entries below the mountpoint are skipped, and the walk stops at the first entry modified after stopAfter
+func lastWrite(mountpoint string, stopAfter time.Time) time.Time {
+	var latest time.Time
+	if mountpoint == "" {
+		return latest
+	}
+
+	err := filepath.WalkDir(mountpoint, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == mountpoint {
+				return err
+			}
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		if !stopAfter.IsZero() && latest.After(stopAfter) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}
+	}
+	return latest
+}
@@ -0,0 +1,108 @@
+package units
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Day is the length of a day as used by age settings
+const Day = 24 * time.Hour
+
+// Week is the length of a week as used by age settings
+const Week = 7 * Day
+
+// sizeUnits maps size suffixes to their multiplier in bytes
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// ParseDuration parses an age such as "30", "36h", "14d" or "2w"
+// A bare number is interpreted as a number of days
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	if days, err := strconv.Atoi(s); err == nil {
+		if days < 0 {
+			return 0, fmt.Errorf("invalid duration %q: must not be negative", s)
+		}
+		return time.Duration(days) * Day, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": Day, "w": Week} {
+		if number, ok := strings.CutSuffix(s, suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// FormatDuration formats a duration using the largest whole unit among days, hours, minutes and seconds
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d == 0:
+		return "0d"
+	case d%Day == 0:
+		return fmt.Sprintf("%dd", d/Day)
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
+
+// ParseSize parses a size such as "512", "500MB", "10GB" or "1.5GiB" into bytes
+// Decimal suffixes (KB, MB, GB, TB) use powers of 1000, binary ones (KiB, MiB, GiB, TiB) powers of 1024
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+
+	number, suffix := s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	unit, ok := sizeUnits[suffix]
+	if number == "" || !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// FormatSize formats a size in bytes using the largest decimal unit that keeps it whole
+func FormatSize(size int64) string {
+	for _, suffix := range []string{"TB", "GB", "MB", "KB"} {
+		if unit := sizeUnits[suffix]; size >= unit && size%unit == 0 {
+			return fmt.Sprintf("%d%s", size/unit, suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
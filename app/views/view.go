@@ -1,12 +1,16 @@
 package views
 
 import (
+	"docker-cleanup/app/models"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
@@ -17,6 +21,9 @@ type View struct {
 	YellowText func(format string, a ...interface{}) string
 	GreenText  func(format string, a ...interface{}) string
 	RedText    func(format string, a ...interface{}) string
+
+	// GroupByProject groups the resources listed in dry-run mode by Docker Compose project
+	GroupByProject bool
 }
 
 // NewView creates a new View instance
@@ -70,7 +77,7 @@ func (v *View) ShowDiskUsage(diskUsage *types.DiskUsage) {
 }
 
 // ShowContainers displays the list of containers
-func (v *View) ShowContainers(containers []types.Container, dryRun bool) {
+func (v *View) ShowContainers(containers []models.StoppedContainer, dryRun bool) {
 	if len(containers) == 0 {
 		v.ShowSuccess("No stopped containers to remove.")
 		return
@@ -80,9 +87,64 @@ func (v *View) ShowContainers(containers []types.Container, dryRun bool) {
 
 	if dryRun {
 		v.ShowTitle("[DRY RUN] The following containers would be removed:")
-		for _, container := range containers {
-			fmt.Printf(" - %s (%s)\n", container.ID[:12], strings.Join(container.Names, ", "))
+		listItems(v, containers, func(container models.StoppedContainer) map[string]string { return container.Labels }, func(container models.StoppedContainer) string {
+			line := fmt.Sprintf("%s (%s) [%s", container.ID[:12], strings.Join(container.Names, ", "), container.Lifecycle())
+			if container.Exited() {
+				line += fmt.Sprintf(", code %d %s", container.ExitCode, humanize.Time(container.FinishedAt))
+			}
+			return line + "]"
+		})
+	}
+}
+
+// listItems displays one line per item, grouped under their Docker Compose project when GroupByProject is set
+func listItems[T any](v *View, items []T, labels func(T) map[string]string, line func(T) string) {
+	if !v.GroupByProject {
+		for _, item := range items {
+			fmt.Printf(" - %s\n", line(item))
 		}
+		return
+	}
+
+	groups := make(map[string][]T)
+	var projects []string
+	for _, item := range items {
+		project := models.ProjectName(labels(item))
+		if _, ok := groups[project]; !ok {
+			projects = append(projects, project)
+		}
+		groups[project] = append(groups[project], item)
+	}
+
+	// Resources outside of any project are listed last
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i] == "" || projects[j] == "" {
+			return projects[j] == ""
+		}
+		return projects[i] < projects[j]
+	})
+
+	for _, project := range projects {
+		if project == "" {
+			fmt.Println(" (no project)")
+		} else {
+			fmt.Printf(" Project %s:\n", project)
+		}
+		for _, item := range groups[project] {
+			fmt.Printf("   - %s\n", line(item))
+		}
+	}
+}
+
+// ShowKept displays the resources kept because they are in use
+func (v *View) ShowKept(kept []models.Kept) {
+	if len(kept) == 0 {
+		return
+	}
+
+	v.ShowTitle("[DRY RUN] The following resources are kept:")
+	for _, k := range kept {
+		fmt.Printf(" - %s %s: %s\n", k.Resource.Kind, k.Resource.Name, k.Reason)
 	}
 }
 
@@ -91,6 +153,32 @@ func (v *View) ShowContainerRemoved(containerID string, names []string) {
 	v.ShowSuccess(fmt.Sprintf("Container removed: %s (%s)", containerID[:12], strings.Join(names, ", ")))
 }
 
+// ShowExpired displays the resources whose TTL or expiry label has passed
+func (v *View) ShowExpired(resources []models.ExpiringResource, dryRun bool) {
+	if len(resources) == 0 {
+		v.ShowSuccess("No expired resources to remove.")
+		return
+	}
+
+	fmt.Printf("Found %d expired resources to remove.\n", len(resources))
+
+	if dryRun {
+		v.ShowTitle("[DRY RUN] The following expired resources would be removed:")
+		listItems(v, resources, func(r models.ExpiringResource) map[string]string { return r.Labels }, func(r models.ExpiringResource) string {
+			line := fmt.Sprintf("%s %s (expired %s)", r.Kind, r.Name, humanize.Time(r.Expires))
+			if r.Running() {
+				line += ", stopped first"
+			}
+			return line
+		})
+	}
+}
+
+// ShowExpiredRemoved displays a message for a removed expired resource
+func (v *View) ShowExpiredRemoved(r models.ExpiringResource) {
+	v.ShowSuccess(fmt.Sprintf("Expired %s removed: %s", r.Kind, r.Name))
+}
+
 // ShowContainersCleanupComplete displays a message for the end of container cleanup
 func (v *View) ShowContainersCleanupComplete() {
 	v.ShowSuccess("Stopped containers successfully removed.")
@@ -105,13 +193,13 @@ func (v *View) ShowImages(images []image.Summary, dryRun bool, imageType string)
 
 	if dryRun {
 		v.ShowTitle(fmt.Sprintf("[DRY RUN] The following %s images would be removed:", imageType))
-		for _, image := range images {
+		listItems(v, images, func(image image.Summary) map[string]string { return image.Labels }, func(image image.Summary) string {
 			tags := image.RepoTags
 			if len(tags) == 0 {
 				tags = []string{"<none>:<none>"}
 			}
-			fmt.Printf(" - %s (%s)\n", image.ID[:12], strings.Join(tags, ", "))
-		}
+			return fmt.Sprintf("%s (%s)", models.ShortID(image.ID), strings.Join(tags, ", "))
+		})
 	}
 }
 
@@ -133,19 +221,104 @@ func (v *View) ShowImagesPruneResult(report image.PruneReport, imageType string)
 	v.ShowSuccess(fmt.Sprintf("%s images successfully removed. Space reclaimed: %s", imageType, FormatSize(report.SpaceReclaimed)))
 }
 
-// ShowVolumes displays the list of volumes
-func (v *View) ShowVolumes(volumes []volume.Volume, dryRun bool) {
+// ShowBudgets displays the storage used by each image budget and the savings of the selected images
+func (v *View) ShowBudgets(groups []models.BudgetGroup) {
+	v.ShowTitle("Image storage budgets (estimated):")
+	for _, group := range groups {
+		status := v.GreenText("within budget")
+		if group.Usage > group.Limit {
+			status = v.RedText("over by %s", FormatSize(uint64(group.Usage-group.Limit)))
+		}
+		fmt.Printf(" - %s: ~%s of %s (%d images), %s\n", group.Pattern, FormatSize(uint64(group.Usage)), FormatSize(uint64(group.Limit)), len(group.Images), status)
+
+		if len(group.Selected) > 0 {
+			fmt.Printf("   %d oldest unused images selected, estimated usage ~%s (saves ~%s)\n",
+				len(group.Selected), FormatSize(uint64(group.Projected)), FormatSize(uint64(max(group.Usage-group.Projected, 0))))
+		}
+		if group.Projected > group.Limit {
+			fmt.Println(v.YellowText("   Budget cannot be met: the remaining images are in use or kept."))
+		}
+	}
+	fmt.Println()
+}
+
+// ShowVolumes displays the list of volumes with their size, age, last write, driver and labels
+func (v *View) ShowVolumes(volumes []models.UnusedVolume, dryRun bool) {
 	if len(volumes) == 0 {
 		v.ShowSuccess("No unused volumes to remove.")
 		return
 	}
 
+	var total int64
+	for _, vol := range volumes {
+		if vol.UsageData != nil {
+			total += max(vol.UsageData.Size, 0)
+		}
+	}
+	fmt.Printf("Found %d volumes to remove, holding %s.\n", len(volumes), FormatSize(uint64(total)))
+
 	if dryRun {
 		v.ShowTitle("[DRY RUN] The following volumes would be removed:")
-		for _, volume := range volumes {
-			fmt.Printf(" - %s\n", volume.Name)
+		listItems(v, volumes, func(vol models.UnusedVolume) map[string]string { return vol.Labels }, func(vol models.UnusedVolume) string {
+			size := "size unknown"
+			if vol.UsageData != nil && vol.UsageData.Size >= 0 {
+				size = FormatSize(uint64(vol.UsageData.Size))
+			}
+			created, _ := time.Parse(time.RFC3339, vol.CreatedAt)
+
+			line := fmt.Sprintf("%s (%s, created %s, last write %s, driver %s)", vol.Name, size, formatActivity(created), formatActivity(vol.LastWrite), vol.Driver)
+			if vol.Database != "" {
+				line += v.RedText(" likely %s database", vol.Database)
+			}
+			if labels := formatLabels(vol.Labels); labels != "" {
+				line += "\n     labels: " + labels
+			}
+			return line
+		})
+	}
+}
+
+// formatLabels formats labels as sorted key=value pairs
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// ShowAnonymousRestoreWarning warns that a restored volume will be taken for an anonymous volume
+func (v *View) ShowAnonymousRestoreWarning(name string) {
+	fmt.Println(v.RedText("Warning: volume %s has a random name and is removed as anonymous while unused, restore it with --name to keep it", name))
+}
+
+// ShowRemoteVolumesWarning warns about the selected volumes whose data may live on a remote host
+func (v *View) ShowRemoteVolumesWarning(volumes []models.UnusedVolume) {
+	var remote []string
+	for _, vol := range volumes {
+		if vol.Remote != "" {
+			remote = append(remote, fmt.Sprintf("%s (%s)", vol.Name, vol.Remote))
 		}
 	}
+	if len(remote) == 0 {
+		return
+	}
+
+	fmt.Println(v.RedText("Warning: %d volumes may hold remote data shared with other hosts, removing them can destroy it:", len(remote)))
+	for _, name := range remote {
+		fmt.Println(v.RedText(" - %s", name))
+	}
+}
+
+// ShowVolumesBackupPlan displays where the volumes would be backed up before their removal
+func (v *View) ShowVolumesBackupPlan(dir string) {
+	fmt.Printf("Each volume would be archived to %s before its removal.\n", dir)
+}
+
+// ShowVolumeBackedUp displays the backup of a volume about to be removed
+func (v *View) ShowVolumeBackedUp(name string, backup models.VolumeBackup) {
+	v.ShowSuccess(fmt.Sprintf("Volume backed up: %s to %s", name, backup.Archive))
 }
 
 // ShowVolumesPruneResult displays the result of volume cleanup
@@ -170,9 +343,9 @@ func (v *View) ShowNetworks(networks []network.Summary, dryRun bool) {
 
 	if dryRun {
 		v.ShowTitle("[DRY RUN] The following networks would be removed:")
-		for _, network := range networks {
-			fmt.Printf(" - %s (%s)\n", network.Name, network.ID[:12])
-		}
+		listItems(v, networks, func(network network.Summary) map[string]string { return network.Labels }, func(network network.Summary) string {
+			return fmt.Sprintf("%s (%s)", network.Name, network.ID[:12])
+		})
 	}
 }
 
@@ -189,11 +362,321 @@ func (v *View) ShowNetworksPruneResult(report network.PruneReport) {
 	v.ShowSuccess("Unused networks successfully removed.")
 }
 
+// ShowIPAMReport displays the subnets of the networks with the address pool they consume, and the subnets left in each pool
+func (v *View) ShowIPAMReport(usage *models.IPAMUsage) {
+	if len(usage.Networks) == 0 {
+		fmt.Println("No network has an IPv4 subnet.")
+	} else {
+		fmt.Printf("%-30s  %-10s  %-8s  %-18s  %s\n", "NETWORK", "DRIVER", "SCOPE", "SUBNET", "POOL")
+		for _, net := range usage.Networks {
+			pool := net.Pool
+			if pool == "" {
+				pool = "-"
+			}
+			fmt.Printf("%-30s  %-10s  %-8s  %-18s  %s\n", net.Name, net.Driver, net.Scope, net.Subnet, pool)
+		}
+	}
+	fmt.Println()
+
+	source := "default"
+	if usage.Configured {
+		source = "configured"
+	}
+	fmt.Printf("Address pools (%s):\n", source)
+	fmt.Printf("%-22s  %6s  %6s  %6s\n", "POOL", "USED", "FREE", "TOTAL")
+	for _, pool := range usage.Pools {
+		line := fmt.Sprintf("%-22s  %6d  %6d  %6d", pool, pool.Used, pool.Free(), pool.Subnets())
+		if pool.Free() == 0 {
+			line = v.RedText("%s", line)
+		}
+		fmt.Println(line)
+	}
+	if usage.Free() == 0 {
+		fmt.Println(v.RedText("No subnet left in the address pools: new networks will fail to find a non-overlapping address pool"))
+		return
+	}
+	v.ShowSuccess(fmt.Sprintf("%d subnets free in the address pools.", usage.Free()))
+}
+
+// ShowSubnetsFree displays the subnets left in the address pools, in red when fewer than wanted
+func (v *View) ShowSubnetsFree(free int, wanted int) {
+	if free < wanted {
+		fmt.Println(v.RedText("%d subnets free in the address pools, %d wanted: new networks may fail to find a non-overlapping address pool", free, wanted))
+		return
+	}
+	v.ShowSuccess(fmt.Sprintf("%d subnets free in the address pools.", free))
+}
+
+// ShowConfigs displays the list of swarm configs
+func (v *View) ShowConfigs(configs []swarm.Config, dryRun bool) {
+	if len(configs) == 0 {
+		v.ShowSuccess("No unused configs to remove.")
+		return
+	}
+
+	fmt.Printf("Found %d configs to remove.\n", len(configs))
+
+	if dryRun {
+		v.ShowTitle("[DRY RUN] The following configs would be removed:")
+		listItems(v, configs, func(config swarm.Config) map[string]string { return config.Spec.Labels }, func(config swarm.Config) string {
+			return fmt.Sprintf("%s (%s, created %s)", config.Spec.Name, config.ID[:12], humanize.Time(config.CreatedAt))
+		})
+	}
+}
+
+// ShowConfigsRemoved displays the result of config cleanup
+func (v *View) ShowConfigsRemoved(configs []swarm.Config) {
+	for _, config := range configs {
+		v.ShowSuccess(fmt.Sprintf("Config removed: %s (%s)", config.Spec.Name, config.ID[:12]))
+	}
+	v.ShowSuccess(fmt.Sprintf("Unused configs successfully removed: %d.", len(configs)))
+}
+
+// ShowSecrets displays the list of swarm secrets
+func (v *View) ShowSecrets(secrets []swarm.Secret, dryRun bool) {
+	if len(secrets) == 0 {
+		v.ShowSuccess("No unused secrets to remove.")
+		return
+	}
+
+	fmt.Printf("Found %d secrets to remove.\n", len(secrets))
+
+	if dryRun {
+		v.ShowTitle("[DRY RUN] The following secrets would be removed:")
+		listItems(v, secrets, func(secret swarm.Secret) map[string]string { return secret.Spec.Labels }, func(secret swarm.Secret) string {
+			return fmt.Sprintf("%s (%s, created %s)", secret.Spec.Name, secret.ID[:12], humanize.Time(secret.CreatedAt))
+		})
+	}
+}
+
+// ShowSecretsRemoved displays the result of secret cleanup
+func (v *View) ShowSecretsRemoved(secrets []swarm.Secret) {
+	for _, secret := range secrets {
+		v.ShowSuccess(fmt.Sprintf("Secret removed: %s (%s)", secret.Spec.Name, secret.ID[:12]))
+	}
+	v.ShowSuccess(fmt.Sprintf("Unused secrets successfully removed: %d.", len(secrets)))
+}
+
+// ShowPlugins displays the list of plugins with the reason each is removed
+func (v *View) ShowPlugins(plugins []models.UnusedPlugin, dryRun bool) {
+	if len(plugins) == 0 {
+		v.ShowSuccess("No unused plugins to remove.")
+		return
+	}
+
+	fmt.Printf("Found %d plugins to remove.\n", len(plugins))
+
+	if dryRun {
+		v.ShowTitle("[DRY RUN] The following plugins would be removed:")
+		for _, plugin := range plugins {
+			fmt.Printf(" - %s (%s): %s\n", plugin.Name, plugin.ID[:12], plugin.Reason)
+		}
+	}
+}
+
+// ShowPluginsRemoved displays the result of plugin cleanup
+func (v *View) ShowPluginsRemoved(plugins []models.UnusedPlugin) {
+	for _, plugin := range plugins {
+		v.ShowSuccess(fmt.Sprintf("Plugin removed: %s", plugin.Name))
+	}
+	v.ShowSuccess(fmt.Sprintf("Unused plugins successfully removed: %d.", len(plugins)))
+}
+
+// ShowScaledDownServices displays the services scaled to zero with the resources they keep in use
+func (v *View) ShowScaledDownServices(services []models.ScaledDownService, dryRun bool) {
+	if len(services) == 0 {
+		v.ShowSuccess("No services scaled to zero to remove.")
+		return
+	}
+
+	fmt.Printf("Found %d services scaled to zero.\n", len(services))
+
+	if dryRun {
+		v.ShowTitle("[DRY RUN] The following services would be removed:")
+	}
+	for _, service := range services {
+		fmt.Printf(" - %s (%s), last updated %s\n", service.Spec.Name, service.ID[:12], humanize.Time(service.UpdatedAt))
+		if service.Image != "" {
+			fmt.Printf("     image:    %s\n", service.Image)
+		}
+		if len(service.Networks) > 0 {
+			fmt.Printf("     networks: %s\n", strings.Join(service.Networks, ", "))
+		}
+		if len(service.Configs) > 0 {
+			fmt.Printf("     configs:  %s\n", strings.Join(service.Configs, ", "))
+		}
+		if len(service.Secrets) > 0 {
+			fmt.Printf("     secrets:  %s\n", strings.Join(service.Secrets, ", "))
+		}
+	}
+}
+
+// ShowServicesRemoved displays the result of service cleanup
+func (v *View) ShowServicesRemoved(services []models.ScaledDownService) {
+	for _, service := range services {
+		v.ShowSuccess(fmt.Sprintf("Service removed: %s", service.Spec.Name))
+	}
+	v.ShowSuccess(fmt.Sprintf("Services successfully removed: %d. The resources they used can now be cleaned.", len(services)))
+}
+
+// ShowProjects displays the Docker Compose projects with their state and resources
+func (v *View) ShowProjects(projects []models.Project) {
+	if len(projects) == 0 {
+		v.ShowSuccess("No Docker Compose projects found.")
+		return
+	}
+
+	for _, project := range projects {
+		state := project.State()
+		switch state {
+		case models.ProjectRunning:
+			state = v.GreenText(state)
+		case models.ProjectPartiallyStopped:
+			state = v.YellowText(state)
+		}
+
+		fmt.Printf(" - %s: %s, last activity %s\n", project.Name, state, formatActivity(project.LastActivity))
+		fmt.Printf("   %d containers (%d running), %d networks, %d volumes, %d images\n",
+			len(project.Containers), project.Running, len(project.Networks), len(project.Volumes), len(project.Images))
+		if project.Orphaned != "" {
+			fmt.Println(v.RedText("   orphaned: %s", project.Orphaned))
+		}
+	}
+}
+
+// ShowStaleProjects displays the stale projects about to be removed
+func (v *View) ShowStaleProjects(projects []models.Project, dryRun bool, includeVolumes bool) {
+	if len(projects) == 0 {
+		v.ShowSuccess("No stale projects to remove.")
+		return
+	}
+
+	fmt.Printf("Found %d stale projects to remove.\n", len(projects))
+	if dryRun {
+		v.ShowTitle("[DRY RUN] The following projects would be removed:")
+	} else {
+		v.ShowTitle("The following projects will be removed:")
+	}
+	for _, project := range projects {
+		reason := fmt.Sprintf("inactive, last activity %s", formatActivity(project.LastActivity))
+		if project.Orphaned != "" {
+			reason = "orphaned: " + project.Orphaned
+		}
+		fmt.Printf(" - %s (%s)\n", project.Name, reason)
+		for _, container := range project.Containers {
+			fmt.Printf("   container %s (%s)\n", container.ID[:12], strings.Join(container.Names, ", "))
+		}
+		for _, network := range project.Networks {
+			fmt.Printf("   network %s\n", network.Name)
+		}
+		for _, volume := range project.Volumes {
+			if includeVolumes {
+				fmt.Printf("   volume %s\n", volume.Name)
+			} else {
+				fmt.Printf("   volume %s (kept, use --include-volumes to remove)\n", volume.Name)
+			}
+		}
+	}
+}
+
+// ShowProjectSkipped displays a project that was asked for but cannot be removed
+func (v *View) ShowProjectSkipped(project models.Project, reason string) {
+	fmt.Println(v.YellowText("Project %s skipped: %s.", project.Name, reason))
+}
+
+// ShowBuilders displays the buildx builder nodes with their state, size and last activity
+func (v *View) ShowBuilders(builders []models.Builder) {
+	if len(builders) == 0 {
+		v.ShowSuccess("No buildx builders found.")
+		return
+	}
+
+	for _, builder := range builders {
+		fmt.Printf(" - %s: %s, %s, last activity %s\n", builder.Name, v.builderState(builder), FormatSize(uint64(builder.Size)), formatActivity(builder.LastActivity))
+	}
+}
+
+// builderState describes whether a builder node is running, stopped or only left with its state volume
+func (v *View) builderState(builder models.Builder) string {
+	switch {
+	case builder.Running:
+		return v.GreenText("running")
+	case builder.Container == nil:
+		return v.YellowText("state volume only")
+	default:
+		return "stopped"
+	}
+}
+
+// ShowStaleBuilders displays the builder nodes about to be removed
+func (v *View) ShowStaleBuilders(builders []models.Builder, dryRun bool) {
+	if len(builders) == 0 {
+		v.ShowSuccess("No stale builders to remove.")
+		return
+	}
+
+	var total int64
+	for _, builder := range builders {
+		total += builder.Size
+	}
+	fmt.Printf("Found %d stale builders to remove, holding %s.\n", len(builders), FormatSize(uint64(total)))
+
+	if dryRun {
+		v.ShowTitle("[DRY RUN] The following builders would be removed:")
+	} else {
+		v.ShowTitle("The following builders will be removed:")
+	}
+	for _, builder := range builders {
+		fmt.Printf(" - %s (%s, %s, last activity %s)\n", builder.Name, v.builderState(builder), FormatSize(uint64(builder.Size)), formatActivity(builder.LastActivity))
+		if builder.Container != nil {
+			name := builder.Container.ID[:12]
+			if len(builder.Container.Names) > 0 {
+				name = strings.TrimPrefix(builder.Container.Names[0], "/")
+			}
+			fmt.Printf("   container %s\n", name)
+		}
+		if builder.Volume != "" {
+			fmt.Printf("   volume %s\n", builder.Volume)
+		}
+	}
+}
+
+// ShowBuilderRemoved displays a removed builder node
+func (v *View) ShowBuilderRemoved(builder models.Builder) {
+	v.ShowSuccess(fmt.Sprintf("Builder %s removed. Space reclaimed: %s", builder.Name, FormatSize(uint64(builder.Size))))
+}
+
+// formatActivity formats the time of the last activity of a resource
+func formatActivity(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return humanize.Time(t)
+}
+
 // ShowCleanupComplete displays a message for the end of global cleanup
 func (v *View) ShowCleanupComplete() {
 	v.ShowSuccess("\nGlobal cleanup completed successfully!")
 }
 
+// ShowConfig displays the effective configuration
+func (v *View) ShowConfig(source string, content string) {
+	if source == "" {
+		source = "no policy file, built-in defaults"
+	}
+	fmt.Printf("# Source: %s\n", source)
+	fmt.Print(content)
+}
+
+// ShowConfigValid displays the result of a successful configuration check
+func (v *View) ShowConfigValid(source string) {
+	if source == "" {
+		v.ShowSuccess("No policy file found, built-in defaults are used.")
+		return
+	}
+	v.ShowSuccess(fmt.Sprintf("Policy file %s is valid.", source))
+}
+
 // FormatSize formats a size in bytes to a readable unit
 func FormatSize(size uint64) string {
 	const (
@@ -232,6 +715,28 @@ func (v *View) ShowBuilds(builds []types.BuildCache, dryRun bool) {
 	}
 }
 
+// ShowBuildsReport displays the build cache groups with their size, usage and shared bytes
+func (v *View) ShowBuildsReport(groups []models.BuildGroup) {
+	if len(groups) == 0 {
+		fmt.Println("Build cache is empty.")
+		return
+	}
+
+	var total, shared int64
+	for _, group := range groups {
+		total += group.Size
+		shared += group.Shared
+	}
+	fmt.Printf("Total: %s in %d groups, %s shared, %s exclusive\n\n", FormatSize(uint64(total)), len(groups), FormatSize(uint64(shared)), FormatSize(uint64(total-shared)))
+
+	fmt.Printf("%-10s  %-10s  %-10s  %7s  %5s  %-16s  %s\n", "SIZE", "SHARED", "EXCLUSIVE", "RECORDS", "USES", "LAST USED", "TYPE / STEP")
+	for _, group := range groups {
+		fmt.Printf("%-10s  %-10s  %-10s  %7d  %5d  %-16s  %s: %s\n",
+			FormatSize(uint64(group.Size)), FormatSize(uint64(group.Shared)), FormatSize(uint64(group.Size-group.Shared)),
+			group.Records, group.UsageCount, formatActivity(group.LastUsed), group.Type, group.Step)
+	}
+}
+
 // ShowBuildsPruneResult displays the result of builds cleanup
 func (v *View) ShowBuildsPruneResult(report *types.BuildCachePruneReport) {
 	if len(report.CachesDeleted) == 0 {
@@ -0,0 +1,223 @@
+package workspace
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// References maps the images, volumes and networks referenced by a workspace to the file referencing them
+type References struct {
+	Images   map[string]string
+	Volumes  map[string]string
+	Networks map[string]string
+}
+
+// skippedDirs are directories that never hold project files worth scanning
+var skippedDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+	"vendor":       true,
+	".venv":        true,
+}
+
+// Scan walks the directories and collects the resources referenced by their Compose files and Dockerfiles
+func Scan(dirs []string) (*References, error) {
+	refs := &References{
+		Images:   make(map[string]string),
+		Volumes:  make(map[string]string),
+		Networks: make(map[string]string),
+	}
+
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// Unreadable directories are skipped rather than aborting the scan
+				if entry != nil && entry.IsDir() && path != dir {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			if entry.IsDir() {
+				if skippedDirs[entry.Name()] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			switch {
+			case isComposeFile(entry.Name()):
+				return refs.scanCompose(path)
+			case strings.HasPrefix(entry.Name(), "Dockerfile"):
+				return refs.scanDockerfile(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return refs, nil
+}
+
+// isComposeFile reports whether a file name is one of the names Docker Compose looks for
+func isComposeFile(name string) bool {
+	ext := filepath.Ext(name)
+	if ext != ".yml" && ext != ".yaml" {
+		return false
+	}
+	return strings.HasPrefix(name, "docker-compose") || strings.HasPrefix(name, "compose")
+}
+
+// NormalizeImage returns an image reference in the short form used by image tags, with an explicit tag
+func NormalizeImage(ref string) string {
+	ref = strings.TrimPrefix(ref, "docker.io/")
+	ref = strings.TrimPrefix(ref, "library/")
+
+	if strings.Contains(ref, "@") {
+		return ref
+	}
+	if i := strings.LastIndex(ref, ":"); i < 0 || i < strings.LastIndex(ref, "/") {
+		ref += ":latest"
+	}
+	return ref
+}
+
+// composeFile holds the parts of a Compose file that reference images, volumes and networks
+type composeFile struct {
+	Name     string                    `yaml:"name"`
+	Services map[string]composeService `yaml:"services"`
+	Volumes  map[string]*composeNamed  `yaml:"volumes"`
+	Networks map[string]*composeNamed  `yaml:"networks"`
+}
+
+type composeService struct {
+	Image   string      `yaml:"image"`
+	Build   interface{} `yaml:"build"`
+	Volumes []yaml.Node `yaml:"volumes"`
+}
+
+type composeNamed struct {
+	Name     string      `yaml:"name"`
+	External interface{} `yaml:"external"`
+}
+
+// projectNamePattern matches the characters Compose drops from directory names
+var projectNamePattern = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// scanCompose collects the images, named volumes and networks of a Compose file
+// Volumes and networks are recorded both under their key and under the project-prefixed name Compose creates
+func (r *References) scanCompose(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var file composeFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		// Files that merely look like Compose files are ignored
+		return nil
+	}
+
+	project := file.Name
+	if project == "" {
+		project = projectNamePattern.ReplaceAllString(strings.ToLower(filepath.Base(filepath.Dir(path))), "")
+	}
+
+	for name, service := range file.Services {
+		if service.Image != "" {
+			r.Images[NormalizeImage(service.Image)] = path
+		} else if service.Build != nil {
+			// Images built by Compose are named after the project and service
+			r.Images[NormalizeImage(project+"-"+name)] = path
+			r.Images[NormalizeImage(project+"_"+name)] = path
+		}
+
+		for _, node := range service.Volumes {
+			if source := volumeSource(node); source != "" {
+				r.Volumes[source] = path
+				r.Volumes[project+"_"+source] = path
+			}
+		}
+	}
+
+	for key, volume := range file.Volumes {
+		r.Volumes[key] = path
+		r.Volumes[project+"_"+key] = path
+		if volume != nil && volume.Name != "" {
+			r.Volumes[volume.Name] = path
+		}
+	}
+
+	r.Networks[project+"_default"] = path
+	for key, network := range file.Networks {
+		r.Networks[key] = path
+		r.Networks[project+"_"+key] = path
+		if network != nil && network.Name != "" {
+			r.Networks[network.Name] = path
+		}
+	}
+
+	return nil
+}
+
+// volumeSource returns the named volume of a service volume entry, empty for bind mounts
+func volumeSource(node yaml.Node) string {
+	var source string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		source, _, _ = strings.Cut(node.Value, ":")
+	case yaml.MappingNode:
+		var long struct {
+			Type   string `yaml:"type"`
+			Source string `yaml:"source"`
+		}
+		if err := node.Decode(&long); err != nil || long.Type != "volume" {
+			return ""
+		}
+		source = long.Source
+	}
+
+	if source == "" || strings.ContainsAny(source[:1], "./~$") {
+		return ""
+	}
+	return source
+}
+
+// fromPattern matches FROM instructions, with optional flags and stage name
+var fromPattern = regexp.MustCompile(`(?i)^\s*FROM\s+(?:--\S+\s+)*(\S+)(?:\s+AS\s+(\S+))?`)
+
+// scanDockerfile collects the base images of a Dockerfile, skipping build stages and unresolved arguments
+func (r *References) scanDockerfile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	stages := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		match := fromPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		image := match[1]
+		if match[2] != "" {
+			stages[strings.ToLower(match[2])] = true
+		}
+		if image == "scratch" || stages[strings.ToLower(image)] || strings.Contains(image, "$") {
+			continue
+		}
+		r.Images[NormalizeImage(image)] = path
+	}
+
+	return nil
+}